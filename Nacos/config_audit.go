@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配置变更审计：每一次生效或被拒绝的配置更新都生成一条结构化的审计事件，
+// 写入可插拔的输出(JSON Lines文件、slog，或者ConfigMetrics统计成指标)
+// 事件中只记录变更的字段路径，不记录字段值，避免密码等敏感信息进入审计日志
+
+// 审计事件的结果
+const (
+	AuditApplied   = "applied"   //新配置已生效
+	AuditUnchanged = "unchanged" //内容解析后与当前配置一致，没有通知监听器
+	AuditRejected  = "rejected"  //新配置被拒绝，上一份有效配置继续生效
+)
+
+const (
+	auditMaxChanges   = 100              //审计事件中最多记录的变更字段数
+	auditListenerWait = 30 * time.Second //等待异步监听器处理结果的最长时间
+	auditPending      = "pending"        //等待超时时监听器仍未处理完
+)
+
+// AuditEvent 一次配置更新的审计事件
+type AuditEvent struct {
+	Time        time.Time         `json:"time"`
+	Outcome     string            `json:"outcome"`
+	Namespace   string            `json:"namespace"`
+	DataId      string            `json:"dataId"` //多层配置时用逗号拼接
+	Group       string            `json:"group"`
+	Source      string            `json:"source"`
+	OldMD5      string            `json:"oldMd5"`
+	NewMD5      string            `json:"newMd5"`
+	Version     int               `json:"version,omitempty"` //生效后的本地版本号
+	Stage       string            `json:"stage,omitempty"`   //被拒绝的阶段
+	Error       string            `json:"error,omitempty"`
+	ChangeCount int               `json:"changeCount"`
+	Changes     []string          `json:"changes,omitempty"` //变更摘要，例如 "changed database.url"
+	Listeners   []ListenerOutcome `json:"listeners,omitempty"`
+	DurationMs  float64           `json:"durationMs"` //从收到配置到同步监听器处理完成的耗时
+}
+
+// AuditSink 审计事件的输出
+type AuditSink interface {
+	WriteAudit(event AuditEvent) error
+}
+
+// AuditSinkFunc 函数形式的审计输出
+type AuditSinkFunc func(event AuditEvent) error
+
+// WriteAudit 实现AuditSink接口
+func (f AuditSinkFunc) WriteAudit(event AuditEvent) error {
+	return f(event)
+}
+
+// SetNamespace 设置审计事件中记录的命名空间，应与创建客户端时的NamespaceId一致
+func (cm *ConfigManager[T]) SetNamespace(namespace string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.namespace = namespace
+}
+
+// AddAuditSink 添加审计输出，多个输出按添加顺序写入
+func (cm *ConfigManager[T]) AddAuditSink(sink AuditSink) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.auditSinks = append(cm.auditSinks, sink)
+}
+
+// newAuditEvent 以当前生效的配置为起点创建审计事件，调用方需持有updateMutex
+func (cm *ConfigManager[T]) newAuditEvent(contents []string, source string) *AuditEvent {
+	dataIds := make([]string, len(cm.layers))
+	groups := make([]string, len(cm.layers))
+	for i, layer := range cm.layers {
+		dataIds[i] = layer.DataId
+		groups[i] = layer.Group
+	}
+	cm.mutex.RLock()
+	namespace := cm.namespace
+	cm.mutex.RUnlock()
+
+	return &AuditEvent{
+		Time:      time.Now(),
+		Namespace: namespace,
+		DataId:    strings.Join(dataIds, ","),
+		Group:     strings.Join(groups, ","),
+		Source:    source,
+		OldMD5:    cm.Status().MD5,
+		NewMD5:    layersMD5(contents),
+	}
+}
+
+// reject 记录被拒绝的原因
+func (e *AuditEvent) reject(err error, elapsed time.Duration) {
+	e.Outcome = AuditRejected
+	var rejected *ConfigRejectedError
+	if errors.As(err, &rejected) {
+		e.Stage = rejected.Stage
+	}
+	e.Error = RedactSecrets(err.Error())
+	e.DurationMs = durationMs(elapsed)
+}
+
+// apply 记录生效的版本和变更摘要
+func (e *AuditEvent) apply(version int, diff ConfigDiff, elapsed time.Duration) {
+	e.Outcome = AuditApplied
+	if diff.Empty() {
+		e.Outcome = AuditUnchanged
+	}
+	e.Version = version
+	e.ChangeCount = len(diff)
+	for i, change := range diff {
+		if i == auditMaxChanges {
+			e.Changes = append(e.Changes, fmt.Sprintf("...(另有%d项)", len(diff)-auditMaxChanges))
+			break
+		}
+		e.Changes = append(e.Changes, string(change.Kind)+" "+change.Path)
+	}
+	e.DurationMs = durationMs(elapsed)
+}
+
+// emitAudit 在后台等异步监听器处理完(或超时)后写出审计事件，不阻塞配置更新；
+// 每个事件都等前一个事件写出后再写，保证输出顺序与配置更新顺序一致。调用方需持有updateMutex
+func (cm *ConfigManager[T]) emitAudit(event *AuditEvent, waiters []*listenerWaiter) {
+	cm.mutex.RLock()
+	sinks := cm.auditSinks
+	cm.mutex.RUnlock()
+	if len(sinks) == 0 {
+		return
+	}
+
+	previous := cm.auditTail
+	written := make(chan struct{})
+	cm.auditTail = written
+	go func() {
+		defer close(written)
+		event.Listeners = collectOutcomes(waiters)
+		if previous != nil {
+			<-previous
+		}
+		for _, sink := range sinks {
+			if err := sink.WriteAudit(*event); err != nil {
+				logf("写入配置审计事件失败: %v\n", err)
+			}
+		}
+	}()
+}
+
+// collectOutcomes 等待各监听器的处理结果，超过auditListenerWait仍未完成的记为pending
+func collectOutcomes(waiters []*listenerWaiter) []ListenerOutcome {
+	if len(waiters) == 0 {
+		return nil
+	}
+	timer := time.NewTimer(auditListenerWait)
+	defer timer.Stop()
+
+	outcomes := make([]ListenerOutcome, len(waiters))
+	expired := false
+	for i, waiter := range waiters {
+		if !expired {
+			select {
+			case <-waiter.done:
+				outcomes[i] = waiter.outcome
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+		select {
+		case <-waiter.done:
+			outcomes[i] = waiter.outcome
+		default:
+			outcomes[i] = ListenerOutcome{Listener: waiter.outcome.Listener, Sync: waiter.outcome.Sync, Result: auditPending}
+		}
+	}
+	return outcomes
+}
+
+// durationMs 转换为毫秒，保留小数
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// JSONLinesAuditSink 把审计事件逐行追加到文件中，每行一个JSON对象
+type JSONLinesAuditSink struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewJSONLinesAuditSink 以追加方式打开审计文件，目录不存在时自动创建
+func NewJSONLinesAuditSink(path string) (*JSONLinesAuditSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志文件失败: %v", err)
+	}
+	return &JSONLinesAuditSink{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// WriteAudit 实现AuditSink接口
+func (s *JSONLinesAuditSink) WriteAudit(event AuditEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return errors.New("审计日志文件已关闭")
+	}
+	return s.encoder.Encode(event)
+}
+
+// Close 关闭审计文件
+func (s *JSONLinesAuditSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// SlogAuditSink 通过slog输出审计事件，生效记为Info，被拒绝或有监听器失败时记为Warn
+type SlogAuditSink struct {
+	Logger *slog.Logger //为空时使用slog.Default()
+}
+
+// WriteAudit 实现AuditSink接口
+func (s SlogAuditSink) WriteAudit(event AuditEvent) error {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("outcome", event.Outcome),
+		slog.String("namespace", event.Namespace),
+		slog.String("dataId", event.DataId),
+		slog.String("group", event.Group),
+		slog.String("source", event.Source),
+		slog.String("oldMd5", event.OldMD5),
+		slog.String("newMd5", event.NewMD5),
+		slog.Float64("durationMs", event.DurationMs),
+	}
+	if event.Outcome == AuditRejected {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("stage", event.Stage), slog.String("error", event.Error))
+	} else {
+		attrs = append(attrs, slog.Int("version", event.Version), slog.Int("changeCount", event.ChangeCount), slog.Any("changes", event.Changes))
+	}
+	if len(event.Listeners) > 0 {
+		listeners := make([]interface{}, len(event.Listeners))
+		for i, outcome := range event.Listeners {
+			if outcome.Result != ListenerOK && outcome.Result != ListenerFiltered {
+				level = slog.LevelWarn
+			}
+			listeners[i] = slog.Group(strconv.Itoa(i),
+				slog.String("listener", outcome.Listener),
+				slog.String("result", outcome.Result),
+				slog.Bool("sync", outcome.Sync),
+				slog.Float64("durationMs", outcome.DurationMs))
+		}
+		attrs = append(attrs, slog.Group("listeners", listeners...))
+	}
+	logger.LogAttrs(context.Background(), level, "配置变更审计", attrs...)
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// auditRecorder 把审计事件转发到channel
+type auditRecorder chan AuditEvent
+
+// WriteAudit 实现AuditSink接口
+func (r auditRecorder) WriteAudit(event AuditEvent) error {
+	r <- event
+	return nil
+}
+
+// next 等待下一条审计事件
+func (r auditRecorder) next(t *testing.T) AuditEvent {
+	t.Helper()
+	select {
+	case event := <-r:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待审计事件超时")
+		return AuditEvent{}
+	}
+}
+
+func TestConfigManagerAuditEvents(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1, v2 := appConfig("v1", 8080), appConfig("v2", 8080)
+	publishTestConfig(t, client, v1)
+
+	audits := make(auditRecorder, 16)
+	metrics := NewConfigMetrics()
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	cm.SetNamespace("staging")
+	cm.AddAuditSink(audits)
+	cm.AddAuditSink(metrics)
+	cm.AddListener(ConfigChangeListenerFunc[ConfigData](func(event *ConfigChangeEvent[ConfigData]) {}), WithName("recorder"))
+	cm.AddListener(ConfigChangeListenerFunc[ConfigData](func(event *ConfigChangeEvent[ConfigData]) {}), WithName("db"), WithPaths("database.*"))
+	cm.AddListener(ConfigChangeListenerFunc[ConfigData](func(event *ConfigChangeEvent[ConfigData]) {
+		panic("boom")
+	}), WithName("checker"), WithSync())
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	defer cm.Stop(context.Background())
+
+	// 首次加载
+	event := audits.next(t)
+	if event.Outcome != AuditApplied || event.Namespace != "staging" || event.DataId != testDataId || event.Group != testGroup ||
+		event.Source != SourceNacos || event.OldMD5 != "" || event.NewMD5 != contentMD5(v1) || event.Version != 1 {
+		t.Fatalf("首次加载的审计事件为%+v", event)
+	}
+
+	// 生效：记录变更的字段路径和各监听器的处理结果，同步监听器在前
+	publishTestConfig(t, client, v2)
+	event = audits.next(t)
+	if event.Outcome != AuditApplied || event.OldMD5 != contentMD5(v1) || event.NewMD5 != contentMD5(v2) || event.Version != 2 ||
+		event.ChangeCount != 1 || strings.Join(event.Changes, ";") != "changed appName" {
+		t.Fatalf("生效的审计事件为%+v", event)
+	}
+	want := []ListenerOutcome{
+		{Listener: "checker", Sync: true, Result: ListenerPanic},
+		{Listener: "recorder", Result: ListenerOK},
+		{Listener: "db", Result: ListenerFiltered},
+	}
+	if len(event.Listeners) != len(want) {
+		t.Fatalf("监听器处理结果为%+v", event.Listeners)
+	}
+	for i, outcome := range event.Listeners {
+		if outcome.Listener != want[i].Listener || outcome.Sync != want[i].Sync || outcome.Result != want[i].Result {
+			t.Errorf("第%d个监听器的处理结果为%+v，期望%+v", i+1, outcome, want[i])
+		}
+	}
+
+	// 被拒绝：记录阶段和原因，版本号不变
+	publishTestConfig(t, client, "appName: v3\n")
+	event = audits.next(t)
+	if event.Outcome != AuditRejected || event.Stage != "validate" || event.Error == "" || event.Version != 0 ||
+		event.OldMD5 != contentMD5(v2) || len(event.Listeners) != 0 {
+		t.Fatalf("被拒绝的审计事件为%+v", event)
+	}
+
+	// 回滚：作为一次新的生效记录
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cm.Rollback(ctx, 1); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	event = audits.next(t)
+	if event.Outcome != AuditApplied || event.NewMD5 != contentMD5(v1) || event.Version != 3 || strings.Join(event.Changes, ";") != "changed appName" {
+		t.Fatalf("回滚的审计事件为%+v", event)
+	}
+
+	// 命名的监听器在指标中按名称区分
+	var out strings.Builder
+	if err := metrics.WritePrometheus(&out); err != nil {
+		t.Fatalf("输出指标失败: %v", err)
+	}
+	for _, line := range []string{
+		`nacos_config_updates_total{namespace="staging",data_id="app.yaml",group="test",outcome="applied"} 3`,
+		`nacos_config_updates_total{namespace="staging",data_id="app.yaml",group="test",outcome="rejected"} 1`,
+		`nacos_config_listener_failures_total{listener="checker",result="panic"} 2`,
+		`nacos_config_listener_duration_seconds_count{listener="recorder",result="ok"} 2`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("指标中没有%s", line)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "reflect"
+
+// deepCopy 深拷贝配置对象，切片、map和指针都会重新分配，调用方修改副本不会影响原配置
+// 配置结构体是由解码器生成的树形结构，这里不处理循环引用；未导出字段只做浅拷贝
+func deepCopy[T any](src *T) *T {
+	if src == nil {
+		return nil
+	}
+	dst := new(T)
+	copyValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+	return dst
+}
+
+// copyValue 递归地把src拷贝到dst，dst必须可写
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		copyValue(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		copyValue(elem, src.Elem())
+		dst.Set(elem)
+	case reflect.Struct:
+		// 先整体赋值保留未导出字段，再逐个深拷贝导出字段
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			copyValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			copyValue(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			copyValue(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+// 定义解析yaml文件装载的结构体
+// 定义一个结构体用于存储YAML配置
+type ConfigData struct {
+	AppName    string                 `yaml:"appName" validate:"required"`
+	ServerPort int                    `yaml:"serverPort" validate:"min=1,max=65535"`
+	Database   Database               `yaml:"database"`
+	Features   []string               `yaml:"features"` //对所有人开启的功能
+	Flags      map[string]FeatureFlag `yaml:"flags"`    //需要灰度或定向的功能开关，见feature_flag.go
+}
+
+// EffectiveFlags 合并features和flags得到全部开关定义，同名时以flags为准
+func (c *ConfigData) EffectiveFlags() map[string]FeatureFlag {
+	flags := make(map[string]FeatureFlag, len(c.Features)+len(c.Flags))
+	for _, name := range c.Features {
+		flags[name] = FeatureFlag{Enabled: true}
+	}
+	for name, flag := range c.Flags {
+		flags[name] = flag
+	}
+	return flags
+}
+
+// 数据库配置结构体
+type Database struct {
+	Driver       string `yaml:"driver"` //database/sql驱动名，为空时按url的协议推断
+	Url          string `yaml:"url" validate:"required"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password" secret:"true"` // 在Nacos上写成${secret:db/password}或ENC(...)，不存明文
+	MaxOpenConns int    `yaml:"maxOpenConns" validate:"min=0"`
+	MaxIdleConns int    `yaml:"maxIdleConns" validate:"min=0"`
+}
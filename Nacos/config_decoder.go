@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// 配置解码器注册表，按配置类型(yaml/json/toml/properties/text)选择解码方式
+
+// ErrUnknownConfigType 未注册解码器的配置类型
+var ErrUnknownConfigType = errors.New("未知的配置类型")
+
+// ConfigDecoder 配置解码器接口，将Nacos下发的原始内容解析到目标对象中
+type ConfigDecoder interface {
+	Decode(content []byte, out interface{}) error
+}
+
+// ConfigDecoderFunc 函数形式的配置解码器
+type ConfigDecoderFunc func(content []byte, out interface{}) error
+
+// Decode 实现ConfigDecoder接口
+func (f ConfigDecoderFunc) Decode(content []byte, out interface{}) error {
+	return f(content, out)
+}
+
+var (
+	decoderMutex sync.RWMutex
+	decoders     = map[string]ConfigDecoder{
+		"yaml":       ConfigDecoderFunc(yaml.Unmarshal),
+		"json":       ConfigDecoderFunc(json.Unmarshal),
+		"toml":       ConfigDecoderFunc(toml.Unmarshal),
+		"properties": ConfigDecoderFunc(decodeProperties),
+		"text":       ConfigDecoderFunc(decodeText),
+	}
+	// 配置类型别名
+	decoderAliases = map[string]string{
+		"":      "yaml", // 兼容旧行为：未指定类型时按YAML解析
+		"yml":   "yaml",
+		"txt":   "text",
+		"plain": "text",
+	}
+)
+
+// RegisterDecoder 注册(或覆盖)指定配置类型的解码器
+func RegisterDecoder(configType string, decoder ConfigDecoder) {
+	if decoder == nil {
+		panic("nacos: RegisterDecoder的解码器不能为nil")
+	}
+
+	decoderMutex.Lock()
+	defer decoderMutex.Unlock()
+
+	decoders[normalizeConfigType(configType)] = decoder
+}
+
+// LookupDecoder 根据配置类型查找解码器
+func LookupDecoder(configType string) (ConfigDecoder, error) {
+	decoderMutex.RLock()
+	defer decoderMutex.RUnlock()
+
+	decoder, ok := decoders[normalizeConfigType(configType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, 已支持的类型: %s", ErrUnknownConfigType, configType, strings.Join(registeredConfigTypes(), ", "))
+	}
+	return decoder, nil
+}
+
+// normalizeConfigType 统一配置类型的大小写和别名
+func normalizeConfigType(configType string) string {
+	t := strings.ToLower(strings.TrimSpace(configType))
+	if alias, ok := decoderAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// registeredConfigTypes 返回已注册的配置类型，调用方需持有decoderMutex
+func registeredConfigTypes() []string {
+	types := make([]string, 0, len(decoders))
+	for t := range decoders {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// decodeText 纯文本配置，只能解码到*string、*[]byte或实现了encoding.TextUnmarshaler的类型
+func decodeText(content []byte, out interface{}) error {
+	switch v := out.(type) {
+	case *string:
+		*v = string(content)
+	case *[]byte:
+		*v = append((*v)[:0], content...)
+	case encoding.TextUnmarshaler:
+		return v.UnmarshalText(content)
+	default:
+		return fmt.Errorf("text配置无法解码到%T，请使用*string或实现encoding.TextUnmarshaler", out)
+	}
+	return nil
+}
+
+// decodeProperties 解析Java风格的.properties配置
+// 点号分隔的key映射到嵌套字段(database.url -> Database.Url)，
+// 列表既可以写成 features=a,b 也可以写成 features[0]=a
+func decodeProperties(content []byte, out interface{}) error {
+	entries, err := parseProperties(content)
+	if err != nil {
+		return err
+	}
+
+	switch v := out.(type) {
+	case *map[string]string:
+		*v = entries
+		return nil
+	case *map[string]interface{}:
+		root, ok := inferScalars(nestProperties(entries)).(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("properties顶层key不能全部是数字下标")
+		}
+		*v = root
+		return nil
+	}
+
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("properties配置需要解码到非nil指针, 实际为%T", out)
+	}
+	return assignProperty(rv.Elem(), "", nestProperties(entries))
+}
+
+// parseProperties 逐行解析properties内容，支持#/!注释、=/:分隔符和行尾反斜杠续行
+func parseProperties(content []byte) (map[string]string, error) {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+
+	var logical strings.Builder
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical.Len() == 0 && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		// 奇数个反斜杠结尾表示续行
+		trailing := len(line) - len(strings.TrimRight(line, "\\"))
+		if trailing%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+
+		key, value := splitProperty(logical.String())
+		logical.Reset()
+		if key == "" {
+			return nil, fmt.Errorf("properties第%d行缺少key", lineNo)
+		}
+		entries[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logical.Len() > 0 {
+		key, value := splitProperty(logical.String())
+		entries[key] = value
+	}
+	return entries, nil
+}
+
+// splitProperty 按第一个未转义的=、:或空白拆分key和value
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t':
+			key := unescapeProperty(strings.TrimSpace(line[:i]))
+			rest := strings.TrimLeft(line[i:], " \t")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t")
+			}
+			return key, unescapeProperty(rest)
+		}
+	}
+	return unescapeProperty(strings.TrimSpace(line)), ""
+}
+
+// unescapeProperty 处理常见的转义字符
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// nestProperties 把扁平的点号key转换为嵌套map，features[0]形式的下标作为子key保存
+func nestProperties(entries map[string]string) map[string]interface{} {
+	root := make(map[string]interface{})
+	for key, value := range entries {
+		segments := splitConfigPath(key)
+		node := root
+		for i, seg := range segments {
+			if i == len(segments)-1 {
+				node[seg] = value
+				break
+			}
+			child, ok := node[seg].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[seg] = child
+			}
+			node = child
+		}
+	}
+	return root
+}
+
+// splitConfigPath 拆分 a.b[0].c 为 [a b 0 c]
+func splitConfigPath(key string) []string {
+	key = strings.NewReplacer("[", ".", "]", "").Replace(key)
+	return strings.Split(key, ".")
+}
+
+// inferScalars 把properties中的字符串值推断为数字/布尔，下标子map转换为列表
+func inferScalars(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = inferScalars(child)
+		}
+		if keys, ok := indexKeys(v); ok {
+			list := make([]interface{}, len(keys))
+			for i, k := range keys {
+				list[i] = v[k]
+			}
+			return list
+		}
+		return v
+	case string:
+		if strings.EqualFold(v, "true") || strings.EqualFold(v, "false") {
+			return strings.EqualFold(v, "true")
+		}
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return int(i)
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// indexKeys 判断map的key是否全部为数字下标，是则按下标排序返回
+func indexKeys(m map[string]interface{}) ([]string, bool) {
+	if len(m) == 0 {
+		return nil, false
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if _, err := strconv.Atoi(k); err != nil {
+			return nil, false
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(keys[i])
+		b, _ := strconv.Atoi(keys[j])
+		return a < b
+	})
+	return keys, true
+}
+
+// assignProperty 将嵌套的properties节点写入目标值
+func assignProperty(v reflect.Value, path string, node interface{}) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assignProperty(v.Elem(), path, node)
+	}
+
+	switch n := node.(type) {
+	case string:
+		return assignScalar(v, path, n)
+	case map[string]interface{}:
+		switch v.Kind() {
+		case reflect.Struct:
+			for key, child := range n {
+				field, ok := fieldByConfigName(v, key)
+				if !ok {
+					continue // 与yaml/json解码一致，忽略未知字段
+				}
+				if err := assignProperty(field, joinPath(path, key), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		case reflect.Slice:
+			keys, ok := indexKeys(n)
+			if !ok {
+				return fmt.Errorf("properties字段%s需要使用数字下标", path)
+			}
+			slice := reflect.MakeSlice(v.Type(), len(keys), len(keys))
+			for i, k := range keys {
+				if err := assignProperty(slice.Index(i), fmt.Sprintf("%s[%s]", path, k), n[k]); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return fmt.Errorf("properties字段%s的map key必须是string", path)
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			for key, child := range n {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := assignProperty(elem, joinPath(path, key), child); err != nil {
+					return err
+				}
+				v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("properties字段%s无法写入%s类型", path, v.Type())
+}
+
+// assignScalar 按目标类型转换字符串值
+func assignScalar(v reflect.Value, path, raw string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(raw))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("properties字段%s不是合法的布尔值: %v", path, err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("properties字段%s不是合法的整数: %v", path, err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("properties字段%s不是合法的无符号整数: %v", path, err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("properties字段%s不是合法的浮点数: %v", path, err)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		// features=a,b,c 逗号分隔写法
+		parts := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := assignScalar(slice.Index(i), fmt.Sprintf("%s[%d]", path, i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(raw))
+	default:
+		return fmt.Errorf("properties字段%s无法写入%s类型", path, v.Type())
+	}
+	return nil
+}
+
+// fieldByConfigName 按yaml/json/toml标签或字段名(忽略大小写)查找结构体字段
+func fieldByConfigName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if configFieldName(f) == name || strings.EqualFold(f.Name, name) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// configFieldName 返回字段在配置文件中的名称，依次使用yaml、json、toml标签
+func configFieldName(f reflect.StructField) string {
+	for _, key := range []string{"yaml", "json", "toml"} {
+		if tag := f.Tag.Get(key); tag != "" && tag != "-" {
+			if name := strings.Split(tag, ",")[0]; name != "" {
+				return name
+			}
+		}
+	}
+	return f.Name
+}
+
+// joinPath 拼接点号分隔的字段路径
+func joinPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}
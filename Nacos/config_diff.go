@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+)
+
+// 字段级配置差异计算，路径使用配置文件中的字段名，例如 database.url、features[1]
+
+// ChangeKind 字段变更类型
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"   //新增
+	ChangeRemoved  ChangeKind = "removed" //删除
+	ChangeModified ChangeKind = "changed" //修改
+)
+
+// FieldChange 单个字段的变更
+type FieldChange struct {
+	Path string
+	Kind ChangeKind
+	Old  interface{}
+	New  interface{}
+}
+
+func (c FieldChange) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s = %v", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s = %v", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", c.Path, c.Old, c.New)
+	}
+}
+
+// ConfigDiff 两份配置之间的全部字段变更，按路径排序
+type ConfigDiff []FieldChange
+
+// Empty 是否没有任何变更
+func (d ConfigDiff) Empty() bool {
+	return len(d) == 0
+}
+
+// Paths 返回所有变更的字段路径
+func (d ConfigDiff) Paths() []string {
+	paths := make([]string, len(d))
+	for i, c := range d {
+		paths[i] = c.Path
+	}
+	return paths
+}
+
+// Filter 只保留匹配任一路径模式的变更
+func (d ConfigDiff) Filter(patterns ...string) ConfigDiff {
+	var out ConfigDiff
+	for _, c := range d {
+		if matchAnyPath(patterns, c.Path) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Matches 是否存在匹配任一路径模式的变更
+func (d ConfigDiff) Matches(patterns ...string) bool {
+	for _, c := range d {
+		if matchAnyPath(patterns, c.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffConfig 计算两份配置的字段级差异，old为nil时所有字段都视为新增
+func DiffConfig[T any](old, new *T) ConfigDiff {
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+	if old != nil {
+		flattenValue(reflect.ValueOf(old).Elem(), "", before)
+	}
+	if new != nil {
+		flattenValue(reflect.ValueOf(new).Elem(), "", after)
+	}
+
+	var diff ConfigDiff
+	for p, o := range before {
+		n, ok := after[p]
+		switch {
+		case !ok:
+			diff = append(diff, FieldChange{Path: p, Kind: ChangeRemoved, Old: o})
+		case !reflect.DeepEqual(o, n):
+			diff = append(diff, FieldChange{Path: p, Kind: ChangeModified, Old: o, New: n})
+		}
+	}
+	for p, n := range after {
+		if _, ok := before[p]; !ok {
+			diff = append(diff, FieldChange{Path: p, Kind: ChangeAdded, New: n})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+	return diff
+}
+
+// flattenValue 把配置展开成 路径->叶子值 的映射
+func flattenValue(v reflect.Value, prefix string, out map[string]interface{}) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			flattenValue(v.Elem(), prefix, out)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		// 没有导出字段的结构体(例如time.Time)无法展开，作为整体的叶子值比较
+		if !hasExportedField(t) {
+			out[prefix] = v.Interface()
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			flattenValue(v.Field(i), joinPath(prefix, configFieldName(f)), out)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			flattenValue(v.Index(i), fmt.Sprintf("%s[%d]", prefix, i), out)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			flattenValue(iter.Value(), joinPath(prefix, fmt.Sprint(iter.Key().Interface())), out)
+		}
+	default:
+		out[prefix] = v.Interface()
+	}
+}
+
+// hasExportedField 结构体是否有导出字段
+func hasExportedField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyPath 判断路径是否匹配任一模式，没有模式时视为全部匹配
+func matchAnyPath(patterns []string, p string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if MatchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath 判断字段路径是否匹配订阅模式
+// 模式按段匹配，每段支持path.Match通配；模式匹配到某个节点即包含其所有子字段，
+// 因此 "database" 与 "database.*" 都会匹配 database.url，"features" 会匹配 features[0]
+func MatchPath(pattern, p string) bool {
+	patSegs := splitConfigPath(pattern)
+	pathSegs := splitConfigPath(p)
+	if len(pathSegs) < len(patSegs) {
+		return false
+	}
+	for i, seg := range patSegs {
+		ok, err := path.Match(seg, pathSegs[i])
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// FakeConfigClient 进程内的config_client.IConfigClient实现，不需要启动Nacos即可调试ConfigManager、
+// 导入导出等依赖配置客户端的代码
+// 行为尽量与nacos-sdk-go v1.1.4保持一致：
+//   - 同一个dataId/group只保留一个监听，重复ListenConfig会替换之前的回调
+//   - 只有内容的MD5变化时才回调，回调在独立的goroutine中异步执行
+//   - 回调来不及执行时只会收到最新的内容(与长轮询一样，中间版本会被合并)
+//   - 删除配置时以空内容回调
+//   - ListenConfig只在本地登记监听，不请求服务端，注入的错误不影响监听
+type FakeConfigClient struct {
+	mutex     sync.Mutex
+	idle      *sync.Cond //所有回调执行完毕时广播
+	namespace string
+	configs   map[string]*fakeConfig
+	listeners map[string]*fakeListener
+	err       error         //注入的错误，非nil时请求服务端的调用都返回该错误
+	delay     time.Duration //模拟长轮询的推送延迟
+}
+
+// fakeConfig 一份配置
+type fakeConfig struct {
+	dataId     string
+	group      string
+	content    string
+	md5        string
+	configType vo.ConfigType
+}
+
+// fakeListener 一个监听回调及其待投递的内容
+type fakeListener struct {
+	dataId     string
+	group      string
+	onChange   func(namespace, group, dataId, data string)
+	latest     string //待投递的最新内容
+	dirty      bool   //是否有待投递的内容
+	delivering bool   //是否正在执行回调
+	wakeup     chan struct{}
+}
+
+// NewFakeConfigClient 创建进程内配置客户端，namespace会作为回调的第一个参数
+func NewFakeConfigClient(namespace string) *FakeConfigClient {
+	client := &FakeConfigClient{
+		namespace: namespace,
+		configs:   make(map[string]*fakeConfig),
+		listeners: make(map[string]*fakeListener),
+	}
+	client.idle = sync.NewCond(&client.mutex)
+	return client
+}
+
+// SetError 注入错误，模拟Nacos不可用：GetConfig、PublishConfig等请求服务端的调用返回该错误，
+// ListenConfig与SDK一样仍然成功；传nil恢复正常
+func (c *FakeConfigClient) SetError(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.err = err
+}
+
+// SetNotifyDelay 设置变更推送的延迟，模拟长轮询的间隔
+func (c *FakeConfigClient) SetNotifyDelay(delay time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.delay = delay
+}
+
+// Flush 等待所有已经触发的回调执行完毕
+func (c *FakeConfigClient) Flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.busy() {
+		c.idle.Wait()
+	}
+}
+
+// Listening 是否正在监听该配置
+func (c *FakeConfigClient) Listening(dataId, group string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.listeners[fakeKey(dataId, group)]
+	return ok
+}
+
+// GetConfig 实现IConfigClient接口，配置不存在时返回空字符串
+func (c *FakeConfigClient) GetConfig(param vo.ConfigParam) (string, error) {
+	if err := checkConfigParam(param); err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return "", c.err
+	}
+	if config, ok := c.configs[fakeKey(param.DataId, param.Group)]; ok {
+		return config.content, nil
+	}
+	return "", nil
+}
+
+// PublishConfig 实现IConfigClient接口
+func (c *FakeConfigClient) PublishConfig(param vo.ConfigParam) (bool, error) {
+	if err := checkConfigParam(param); err != nil {
+		return false, err
+	}
+	if param.Content == "" {
+		return false, errors.New("[client.PublishConfig] param.content can not be empty")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return false, c.err
+	}
+	key := fakeKey(param.DataId, param.Group)
+	md5sum := contentMD5(param.Content)
+	old, exists := c.configs[key]
+	c.configs[key] = &fakeConfig{
+		dataId:     param.DataId,
+		group:      param.Group,
+		content:    param.Content,
+		md5:        md5sum,
+		configType: param.Type,
+	}
+	if !exists || old.md5 != md5sum {
+		c.notify(key, param.Content)
+	}
+	return true, nil
+}
+
+// DeleteConfig 实现IConfigClient接口，删除不存在的配置也返回成功
+func (c *FakeConfigClient) DeleteConfig(param vo.ConfigParam) (bool, error) {
+	if err := checkConfigParam(param); err != nil {
+		return false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return false, c.err
+	}
+	key := fakeKey(param.DataId, param.Group)
+	if _, ok := c.configs[key]; ok {
+		delete(c.configs, key)
+		c.notify(key, "")
+	}
+	return true, nil
+}
+
+// ListenConfig 实现IConfigClient接口
+func (c *FakeConfigClient) ListenConfig(param vo.ConfigParam) error {
+	if err := checkConfigParam(param); err != nil {
+		return err
+	}
+	if param.OnChange == nil {
+		return errors.New("[client.ListenConfig] param.OnChange can not be nil")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := fakeKey(param.DataId, param.Group)
+	if old, ok := c.listeners[key]; ok {
+		close(old.wakeup)
+	}
+	listener := &fakeListener{
+		dataId:   param.DataId,
+		group:    param.Group,
+		onChange: param.OnChange,
+		wakeup:   make(chan struct{}, 1),
+	}
+	c.listeners[key] = listener
+	go c.deliver(listener)
+	return nil
+}
+
+// CancelListenConfig 实现IConfigClient接口，尚未投递的变更会被丢弃
+func (c *FakeConfigClient) CancelListenConfig(param vo.ConfigParam) error {
+	if err := checkConfigParam(param); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := fakeKey(param.DataId, param.Group)
+	if listener, ok := c.listeners[key]; ok {
+		delete(c.listeners, key)
+		listener.dirty = false
+		close(listener.wakeup)
+		c.idle.Broadcast()
+	}
+	return nil
+}
+
+// SearchConfig 实现IConfigClient接口
+// accurate按dataId/group精确匹配，blur支持*通配；条件为空表示不限制
+func (c *FakeConfigClient) SearchConfig(param vo.SearchConfigParam) (*model.ConfigPage, error) {
+	if param.Search != "accurate" && param.Search != "blur" {
+		return nil, errors.New("[client.searchConfigInner] param.search must be accurate or blur")
+	}
+	if param.PageNo <= 0 {
+		param.PageNo = 1
+	}
+	if param.PageSize <= 0 {
+		param.PageSize = 10
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	var matched []model.ConfigItem
+	for _, config := range c.configs {
+		if !searchMatch(param.Search, param.DataId, config.dataId) || !searchMatch(param.Search, param.Group, config.group) {
+			continue
+		}
+		matched = append(matched, model.ConfigItem{
+			DataId:  config.dataId,
+			Group:   config.group,
+			Content: config.content,
+			Md5:     config.md5,
+			Tenant:  c.namespace,
+		})
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return fakeKey(matched[i].DataId, matched[i].Group) < fakeKey(matched[j].DataId, matched[j].Group)
+	})
+
+	page := &model.ConfigPage{
+		TotalCount:     len(matched),
+		PageNumber:     param.PageNo,
+		PagesAvailable: (len(matched) + param.PageSize - 1) / param.PageSize,
+	}
+	start := (param.PageNo - 1) * param.PageSize
+	if start < len(matched) {
+		end := start + param.PageSize
+		if end > len(matched) {
+			end = len(matched)
+		}
+		page.PageItems = matched[start:end]
+	}
+	return page, nil
+}
+
+// PublishAggr 实现IConfigClient接口，聚合配置不在模拟范围内
+func (c *FakeConfigClient) PublishAggr(param vo.ConfigParam) (bool, error) {
+	return false, errors.New("FakeConfigClient不支持聚合配置")
+}
+
+// notify 把最新内容交给监听者的投递goroutine，调用方需持有mutex
+func (c *FakeConfigClient) notify(key, content string) {
+	listener, ok := c.listeners[key]
+	if !ok {
+		return
+	}
+	listener.latest = content
+	listener.dirty = true
+	select {
+	case listener.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// deliver 投递goroutine，按顺序执行回调，监听被取消或替换后退出
+func (c *FakeConfigClient) deliver(listener *fakeListener) {
+	for range listener.wakeup {
+		c.mutex.Lock()
+		delay := c.delay
+		c.mutex.Unlock()
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+
+		c.mutex.Lock()
+		if !listener.dirty {
+			c.mutex.Unlock()
+			continue
+		}
+		content := listener.latest
+		listener.dirty = false
+		listener.delivering = true
+		namespace := c.namespace
+		c.mutex.Unlock()
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logf("FakeConfigClient回调[%s/%s]时发生panic: %v\n", listener.group, listener.dataId, r)
+				}
+			}()
+			listener.onChange(namespace, listener.group, listener.dataId, content)
+		}()
+
+		c.mutex.Lock()
+		listener.delivering = false
+		c.idle.Broadcast()
+		c.mutex.Unlock()
+	}
+}
+
+// busy 是否还有待执行或正在执行的回调，调用方需持有mutex
+func (c *FakeConfigClient) busy() bool {
+	for _, listener := range c.listeners {
+		if listener.dirty || listener.delivering {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConfigParam 与SDK一样要求dataId和group不能为空
+func checkConfigParam(param vo.ConfigParam) error {
+	if param.DataId == "" {
+		return errors.New("param.dataId can not be empty")
+	}
+	if param.Group == "" {
+		return errors.New("param.group can not be empty")
+	}
+	return nil
+}
+
+// searchMatch 判断搜索条件是否匹配
+func searchMatch(search, pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	if search == "accurate" {
+		return pattern == value
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// fakeKey 配置的唯一标识
+func fakeKey(dataId, group string) string {
+	return fmt.Sprintf("%s+%s", dataId, group)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// 配置版本历史：每次生效的配置都保留在本地，可以对比任意两个版本并一键回滚
+
+const (
+	defaultHistorySize    = 20               //默认保留的历史版本数
+	rollbackVerifyTimeout = 30 * time.Second //ctx没有截止时间时，等待回滚生效的最长时间
+)
+
+// ConfigVersion 一个已生效的配置版本
+type ConfigVersion struct {
+	Version   int       //版本号，从1开始递增，管理器重启后重新计数
+	MD5       string    //全部层原文的MD5，与ConfigStatus.MD5一致
+	Source    string    //配置来源，SourceNacos 或 SourceLocalSnapshot
+	AppliedAt time.Time //生效时间
+	Contents  []string  //各层原文，顺序与配置来源一致
+}
+
+// historyEntry 历史版本及其解析结果
+type historyEntry[T any] struct {
+	ConfigVersion
+	config *T
+}
+
+// SetHistorySize 设置保留的历史版本数，超出时丢弃最旧的版本
+func (cm *ConfigManager[T]) SetHistorySize(size int) {
+	if size < 1 {
+		size = 1
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.historySize = size
+	if len(cm.history) > size {
+		cm.history = append([]historyEntry[T](nil), cm.history[len(cm.history)-size:]...)
+	}
+}
+
+// ListVersions 返回保留的历史版本，按版本号从旧到新排列
+func (cm *ConfigManager[T]) ListVersions() []ConfigVersion {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	versions := make([]ConfigVersion, len(cm.history))
+	for i, entry := range cm.history {
+		versions[i] = entry.ConfigVersion
+		versions[i].Contents = append([]string(nil), entry.Contents...)
+	}
+	return versions
+}
+
+// Diff 计算两个历史版本之间的字段级差异，v1为旧版本
+func (cm *ConfigManager[T]) Diff(v1, v2 int) (ConfigDiff, error) {
+	old, err := cm.findVersion(v1)
+	if err != nil {
+		return nil, err
+	}
+	new, err := cm.findVersion(v2)
+	if err != nil {
+		return nil, err
+	}
+	return DiffConfig(old.config, new.config), nil
+}
+
+// Rollback 把配置回滚到指定的历史版本：通过PublishConfig重新发布该版本的原文，
+// 并等待Nacos把变更推送回来、本管理器实际生效后才返回
+// ctx没有截止时间时最多等待30秒；回滚本身会作为一个新版本记录在历史中
+func (cm *ConfigManager[T]) Rollback(ctx context.Context, version int) error {
+	target, err := cm.findVersion(version)
+	if err != nil {
+		return err
+	}
+
+	cm.lifecycleMutex.Lock()
+	running := cm.running
+	cm.lifecycleMutex.Unlock()
+	if !running {
+		return fmt.Errorf("配置管理器未启动，无法确认回滚是否生效")
+	}
+
+	// 发布之前先按当前的覆盖层、密钥和校验规则检查一遍，避免回滚到一份现在已经无法生效的配置
+	applied := cm.appliedSignal()
+	current, err := cm.validateRollback(target.Contents)
+	if err != nil {
+		return fmt.Errorf("版本%d无法通过当前的校验，放弃回滚: %v", version, err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rollbackVerifyTimeout)
+		defer cancel()
+	}
+
+	// 只重新发布与当前内容不同的层
+	published := 0
+	for i, layer := range cm.layers {
+		if current[i] == target.Contents[i] {
+			continue
+		}
+		ok, err := cm.client.PublishConfig(vo.ConfigParam{
+			DataId:  layer.DataId,
+			Group:   layer.Group,
+			Type:    layer.Type,
+			Content: target.Contents[i],
+		})
+		if err != nil {
+			return fmt.Errorf("回滚配置[%s/%s]时发布失败: %v", layer.Group, layer.DataId, err)
+		}
+		if !ok {
+			return fmt.Errorf("回滚配置[%s/%s]时发布失败", layer.Group, layer.DataId)
+		}
+		published++
+	}
+	if published == 0 {
+		logf("当前配置已经是版本%d的内容，无需回滚\n", version)
+		return nil
+	}
+
+	// 等待监听器收到推送并生效
+	for {
+		if cm.Status().MD5 == target.MD5 {
+			logf("配置已回滚到版本%d\n", version)
+			return nil
+		}
+		select {
+		case <-applied:
+			applied = cm.appliedSignal()
+		case <-ctx.Done():
+			return fmt.Errorf("版本%d已发布，但等待配置生效超时: %w", version, ctx.Err())
+		}
+	}
+}
+
+// findVersion 查找历史版本
+func (cm *ConfigManager[T]) findVersion(version int) (historyEntry[T], error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	for _, entry := range cm.history {
+		if entry.Version == version {
+			return entry, nil
+		}
+	}
+	return historyEntry[T]{}, fmt.Errorf("版本%d不存在或已超出保留范围", version)
+}
+
+// validateRollback 检查回滚目标能否生效，返回各层当前生效的原文
+// 解析密钥会登记脱敏明文，因此与正常的配置更新一样在updateMutex下执行
+func (cm *ConfigManager[T]) validateRollback(contents []string) ([]string, error) {
+	cm.updateMutex.Lock()
+	defer cm.updateMutex.Unlock()
+
+	if _, _, _, err := cm.parseConfig(contents, SourceNacos); err != nil {
+		return nil, err
+	}
+	return append([]string(nil), cm.contents...), nil
+}
+
+// recordHistory 记录一个新生效的版本，与最新版本内容相同时不重复记录，返回版本号
+func (cm *ConfigManager[T]) recordHistory(contents []string, source, md5sum string, config *T) int {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if n := len(cm.history); n > 0 && cm.history[n-1].MD5 == md5sum {
+		return cm.history[n-1].Version
+	}
+
+	size := cm.historySize
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	cm.lastVersion++
+	cm.history = append(cm.history, historyEntry[T]{
+		ConfigVersion: ConfigVersion{
+			Version:   cm.lastVersion,
+			MD5:       md5sum,
+			Source:    source,
+			AppliedAt: time.Now(),
+			Contents:  append([]string(nil), contents...),
+		},
+		config: config,
+	})
+	if len(cm.history) > size {
+		cm.history = append([]historyEntry[T](nil), cm.history[len(cm.history)-size:]...)
+	}
+	return cm.lastVersion
+}
+
+// appliedSignal 返回在下一次配置生效时关闭的通道
+func (cm *ConfigManager[T]) appliedSignal() <-chan struct{} {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if cm.applied == nil {
+		cm.applied = make(chan struct{})
+	}
+	return cm.applied
+}
+
+// signalApplied 唤醒等待配置生效的调用方
+func (cm *ConfigManager[T]) signalApplied() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if cm.applied != nil {
+		close(cm.applied)
+		cm.applied = nil
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+func TestConfigManagerListVersionsAndDiff(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1, v2 := appConfig("demo", 8080), appConfig("demo", 9090)
+	publishTestConfig(t, client, v1)
+	cm := startTestManager(t, client)
+	publishTestConfig(t, client, v2)
+
+	versions := cm.ListVersions()
+	if len(versions) != 2 {
+		t.Fatalf("保留了%d个版本，期望2个", len(versions))
+	}
+	for i, content := range []string{v1, v2} {
+		version := versions[i]
+		if version.Version != i+1 || version.MD5 != contentMD5(content) || version.Source != SourceNacos ||
+			len(version.Contents) != 1 || version.Contents[0] != content || version.AppliedAt.IsZero() {
+			t.Errorf("第%d个版本为%+v", i+1, version)
+		}
+	}
+	if status := cm.Status(); status.Version != 2 || status.MD5 != versions[1].MD5 {
+		t.Errorf("当前状态%+v与最新版本不一致", status)
+	}
+
+	// 返回的是副本，修改不影响保留的历史
+	versions[0].Contents[0] = "changed"
+	if cm.ListVersions()[0].Contents[0] != v1 {
+		t.Error("修改ListVersions的返回值影响了保留的历史")
+	}
+
+	diff, err := cm.Diff(1, 2)
+	if err != nil {
+		t.Fatalf("对比版本失败: %v", err)
+	}
+	if len(diff) != 1 || diff[0].Path != "serverPort" || diff[0].Kind != ChangeModified {
+		t.Errorf("版本1到2的差异为%v，期望只有serverPort被修改", diff)
+	}
+	if _, err := cm.Diff(1, 3); err == nil {
+		t.Error("对比不存在的版本应该报错")
+	}
+
+	// 超出保留数量时丢弃最旧的版本
+	cm.SetHistorySize(1)
+	if versions := cm.ListVersions(); len(versions) != 1 || versions[0].Version != 2 {
+		t.Errorf("缩减保留数量后的版本为%+v", versions)
+	}
+	if _, err := cm.Diff(1, 2); err == nil {
+		t.Error("已丢弃的版本应该无法对比")
+	}
+}
+
+func TestConfigManagerRollback(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1, v2 := appConfig("v1", 8080), appConfig("v2", 8080)
+	publishTestConfig(t, client, v1)
+	cm := startTestManager(t, client)
+	publishTestConfig(t, client, v2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 回滚重新发布历史版本的原文，等本管理器生效后返回，并记录为新版本
+	if err := cm.Rollback(ctx, 1); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if name := cm.GetConfig().AppName; name != "v1" {
+		t.Errorf("回滚后配置为%s，期望v1", name)
+	}
+	if content, _ := client.GetConfig(vo.ConfigParam{DataId: testDataId, Group: testGroup}); content != v1 {
+		t.Errorf("回滚后Nacos上的内容为%q", content)
+	}
+	versions := cm.ListVersions()
+	if len(versions) != 3 || versions[2].MD5 != contentMD5(v1) || cm.Status().Version != 3 {
+		t.Errorf("回滚后的版本为%+v", versions)
+	}
+
+	// 内容已经与目标版本相同时不再发布
+	if err := cm.Rollback(ctx, 3); err != nil {
+		t.Errorf("回滚到当前内容应该直接成功: %v", err)
+	}
+	if n := len(cm.ListVersions()); n != 3 {
+		t.Errorf("回滚到当前内容后有%d个版本", n)
+	}
+}
+
+func TestConfigManagerRollbackFailures(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1, v2 := appConfig("v1", 8080), appConfig("v2", 8080)
+	publishTestConfig(t, client, v1)
+	cm := startTestManager(t, client)
+	publishTestConfig(t, client, v2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := cm.Rollback(ctx, 99); err == nil {
+		t.Error("回滚到不存在的版本应该报错")
+	}
+
+	// 目标版本无法通过当前的校验时不发布
+	cm.AddValidator(func(config *ConfigData) error {
+		if config.AppName == "v1" {
+			return errors.New("v1已下线")
+		}
+		return nil
+	})
+	if err := cm.Rollback(ctx, 1); err == nil {
+		t.Error("目标版本无法通过校验时回滚应该失败")
+	}
+	if content, _ := client.GetConfig(vo.ConfigParam{DataId: testDataId, Group: testGroup}); content != v2 {
+		t.Errorf("校验失败后Nacos上的内容变成了%q", content)
+	}
+
+	// 发布失败时返回错误
+	publishTestConfig(t, client, appConfig("v3", 8080))
+	client.SetError(errors.New("nacos unavailable"))
+	err := cm.Rollback(ctx, 2)
+	client.SetError(nil)
+	if err == nil {
+		t.Error("发布失败时回滚应该报错")
+	}
+
+	// 未启动时无法确认回滚是否生效
+	cm.Stop(context.Background())
+	if err := cm.Rollback(ctx, 2); err == nil {
+		t.Error("配置管理器停止后回滚应该报错")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// 多层配置合并：每层先解码成通用map，再按顺序深度合并
+// 合并规则：map逐个key递归合并，列表和标量整体替换，后面的层优先
+
+// mergeLayers 解码并合并所有层，空内容(dataId尚未发布)的层直接跳过
+// sources中记录每个字段最终来自哪一层
+func (cm *ConfigManager[T]) mergeLayers(contents []string, source string, sources map[string]string) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	for i, layer := range cm.layers {
+		if strings.TrimSpace(contents[i]) == "" {
+			continue
+		}
+
+		decoder, err := cm.resolveDecoder(layer.Type)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]interface{}
+		if err := decoder.Decode([]byte(contents[i]), &values); err != nil {
+			return nil, fmt.Errorf("配置[%s/%s]的%s解析失败: %v", layer.Group, layer.DataId, normalizeConfigType(string(layer.Type)), err)
+		}
+
+		normalized, ok := normalizeValue(values).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("配置[%s/%s]顶层必须是键值结构", layer.Group, layer.DataId)
+		}
+		mergeMaps(merged, normalized)
+		recordLayerSources(normalized, "", layerSource(source, layer), sources)
+	}
+	return merged, nil
+}
+
+// layerSource 字段来源的描述，例如 nacos:DEFAULT_GROUP/app.yaml
+func layerSource(source string, layer vo.ConfigParam) string {
+	return fmt.Sprintf("%s:%s/%s", source, layer.Group, layer.DataId)
+}
+
+// mergeMaps 把src深度合并到dst
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// normalizeValue 把yaml.v2解出的map[interface{}]interface{}统一转换为map[string]interface{}
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			m[fmt.Sprint(key)] = normalizeValue(child)
+		}
+		return m
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = normalizeValue(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = normalizeValue(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// bindMerged 把合并后的map绑定到配置结构体，字段名与其他配置功能一致(yaml/json/toml标签或字段名)，
+// 各层无论使用哪种格式，结构体只需要声明一套标签
+func bindMerged(merged map[string]interface{}, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("合并后的配置只能绑定到非空指针，实际为%T", out)
+	}
+	if err := bindValue(v.Elem(), "", merged); err != nil {
+		return fmt.Errorf("合并后的配置绑定失败: %v", err)
+	}
+	return nil
+}
+
+// bindValue 把解码出的通用值写入目标值，与yaml/json解码一致，结构体中未知的字段被忽略
+func bindValue(v reflect.Value, path string, node interface{}) error {
+	if node == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return bindValue(v.Elem(), path, node)
+	}
+	nv := reflect.ValueOf(node)
+	if nv.Type().AssignableTo(v.Type()) {
+		v.Set(nv)
+		return nil
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		switch v.Kind() {
+		case reflect.Struct:
+			for key, child := range n {
+				field, ok := fieldByConfigName(v, key)
+				if !ok {
+					continue
+				}
+				if err := bindValue(field, joinPath(path, key), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return fmt.Errorf("字段%s的map key必须是string", path)
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			for key, child := range n {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := bindValue(elem, joinPath(path, key), child); err != nil {
+					return err
+				}
+				v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+			}
+			return nil
+		}
+	case []interface{}:
+		switch v.Kind() {
+		case reflect.Slice:
+			slice := reflect.MakeSlice(v.Type(), len(n), len(n))
+			for i, child := range n {
+				if err := bindValue(slice.Index(i), fmt.Sprintf("%s[%d]", path, i), child); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		case reflect.Array:
+			if len(n) > v.Len() {
+				return fmt.Errorf("字段%s最多%d个元素，实际为%d个", path, v.Len(), len(n))
+			}
+			for i, child := range n {
+				if err := bindValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case string:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(n)
+			if err != nil {
+				return fmt.Errorf("字段%s不是合法的时长: %v", path, err)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		return assignScalar(v, path, n)
+	default:
+		if ok, err := convertScalar(v, path, nv); ok {
+			return err
+		}
+	}
+	return fmt.Errorf("字段%s的值%v无法写入%s类型", path, node, v.Type())
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// convertScalar 在数字、布尔值之间转换，json解出的数字都是float64，写入整数字段时不能有小数部分；
+// 写入字符串字段时按文本处理。返回false表示不是可以转换的标量
+func convertScalar(v reflect.Value, path string, nv reflect.Value) (bool, error) {
+	switch nv.Kind() {
+	case reflect.Bool:
+		switch v.Kind() {
+		case reflect.Bool:
+			v.SetBool(nv.Bool())
+			return true, nil
+		case reflect.String:
+			v.SetString(strconv.FormatBool(nv.Bool()))
+			return true, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		f, _ := strconv.ParseFloat(fmt.Sprint(nv.Interface()), 64)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f != math.Trunc(f) || v.OverflowInt(int64(f)) {
+				return true, fmt.Errorf("字段%s的值%v不是合法的%s", path, nv.Interface(), v.Type())
+			}
+			v.SetInt(int64(f))
+			return true, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f < 0 || f != math.Trunc(f) || v.OverflowUint(uint64(f)) {
+				return true, fmt.Errorf("字段%s的值%v不是合法的%s", path, nv.Interface(), v.Type())
+			}
+			v.SetUint(uint64(f))
+			return true, nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+			return true, nil
+		case reflect.String:
+			v.SetString(fmt.Sprint(nv.Interface()))
+			return true, nil
+		}
+	}
+	return false, nil
+}
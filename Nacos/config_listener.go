@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultSyncListenerTimeout 同步监听器未指定超时时间时的默认值，避免一个卡死的监听器阻塞所有配置更新
+const defaultSyncListenerTimeout = 10 * time.Second
+
+// ConfigChangeEvent 配置变更事件
+// Old 为变更前的快照(首次加载时为nil)，New 为变更后的快照，Diff 为两者的字段级差异
+type ConfigChangeEvent[T any] struct {
+	Old  *T
+	New  *T
+	Diff ConfigDiff
+}
+
+// ListenerOption 监听器注册选项
+type ListenerOption func(*listenerOptions)
+
+// listenerOptions 监听器注册选项的集合
+type listenerOptions struct {
+	paths   []string      //订阅的字段路径模式，为空表示订阅全部字段
+	sync    bool          //同步模式，新配置要等该监听器处理完才对GetConfig可见
+	timeout time.Duration //单次回调的超时时间，0表示不限制(同步模式使用默认值)
+	replay  bool          //注册时立即回放当前配置
+	name    string        //监听器名称，用于错误信息、审计事件和指标标签
+}
+
+// WithPaths 只订阅匹配这些路径模式的字段变更，例如 "database.*"、"features"
+func WithPaths(patterns ...string) ListenerOption {
+	return func(o *listenerOptions) {
+		o.paths = append(o.paths, patterns...)
+	}
+}
+
+// WithSync 同步模式：新配置在该监听器处理完成(或超时)之后才会通过GetConfig对外可见
+func WithSync() ListenerOption {
+	return func(o *listenerOptions) {
+		o.sync = true
+	}
+}
+
+// WithTimeout 设置单次回调的超时时间，超时会上报给错误监听器，但后续事件仍按顺序投递
+func WithTimeout(timeout time.Duration) ListenerOption {
+	return func(o *listenerOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithReplay 注册时如果已经有生效的配置，立即把它作为一次变更(Old为nil)投递给监听器，
+// 适用于在Start之后才注册、但需要拿到当前配置做初始化的组件
+func WithReplay() ListenerOption {
+	return func(o *listenerOptions) {
+		o.replay = true
+	}
+}
+
+// WithName 为监听器命名，错误信息、审计事件和指标中使用该名称；
+// 不指定时使用监听器的类型名，所有ConfigChangeListenerFunc会共用同一个名称
+func WithName(name string) ListenerOption {
+	return func(o *listenerOptions) {
+		o.name = name
+	}
+}
+
+// Subscription 监听器的订阅句柄，用于取消订阅
+type Subscription struct {
+	id     uint64
+	cancel func(id uint64) bool
+}
+
+// Unsubscribe 取消订阅，返回本次调用是否注销了监听器；重复调用是安全的
+func (s *Subscription) Unsubscribe() bool {
+	if s == nil || s.cancel == nil {
+		return false
+	}
+	return s.cancel(s.id)
+}
+
+// ListenerTimeoutError 监听器处理配置变更超时
+type ListenerTimeoutError struct {
+	Listener string
+	Timeout  time.Duration
+}
+
+func (e *ListenerTimeoutError) Error() string {
+	return fmt.Sprintf("监听器%s处理配置变更超过%s", e.Listener, e.Timeout)
+}
+
+// ListenerPanicError 监听器处理配置变更时发生panic
+type ListenerPanicError struct {
+	Listener string
+	Value    interface{}
+}
+
+func (e *ListenerPanicError) Error() string {
+	return fmt.Sprintf("监听器%s处理配置变更时发生panic: %v", e.Listener, e.Value)
+}
+
+// 监听器处理一次配置变更的结果
+const (
+	ListenerOK       = "ok"       //正常处理完成
+	ListenerPanic    = "panic"    //回调发生panic
+	ListenerTimeout  = "timeout"  //回调超时
+	ListenerFiltered = "filtered" //订阅的字段没有变化，未回调
+	ListenerDropped  = "dropped"  //投递前监听器已注销
+)
+
+// ListenerOutcome 一个监听器处理一次配置变更的结果，记录在审计事件中
+type ListenerOutcome struct {
+	Listener   string  `json:"listener"`
+	Sync       bool    `json:"sync"`
+	Result     string  `json:"result"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// listenerWaiter 一次投递的等待句柄，done关闭后outcome可读
+type listenerWaiter struct {
+	once    sync.Once
+	done    chan struct{}
+	outcome ListenerOutcome
+}
+
+// pendingEvent 等待投递的事件，连续的多次更新会合并为一次
+type pendingEvent[T any] struct {
+	old     *T                //合并窗口内第一次更新前的快照
+	new     *T                //合并窗口内最后一次更新后的快照
+	waiters []*listenerWaiter //投递完成后需要唤醒的等待者
+}
+
+// listenerEntry 已注册的监听器，每个监听器有独立的投递队列和工作goroutine
+// 同一监听器的事件严格按顺序投递；还没来得及投递的旧事件会被新事件合并，
+// 因此慢监听器不会堆积goroutine，只会在处理完后直接拿到最新的配置
+type listenerEntry[T any] struct {
+	id       uint64
+	listener ConfigChangeListener[T]
+	options  listenerOptions
+	report   func(error) //错误上报
+
+	mutex   sync.Mutex
+	last    *T //最近一次入队的快照，作为下一次事件的Old
+	pending *pendingEvent[T]
+	wakeup  chan struct{}
+	closed  bool
+}
+
+// newListenerEntry 应用注册选项，创建监听器条目并启动投递goroutine
+// current 为注册时已生效的配置，之后的事件都以它为起点计算差异
+func newListenerEntry[T any](id uint64, listener ConfigChangeListener[T], opts []ListenerOption, report func(error), current *T) *listenerEntry[T] {
+	entry := &listenerEntry[T]{
+		id:       id,
+		listener: listener,
+		report:   report,
+		last:     current,
+		wakeup:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(&entry.options)
+	}
+	if entry.options.sync && entry.options.timeout <= 0 {
+		entry.options.timeout = defaultSyncListenerTimeout
+	}
+
+	go entry.run()
+
+	if entry.options.replay && current != nil {
+		entry.last = nil
+		entry.enqueue(current)
+	}
+	return entry
+}
+
+// enqueue 投递一次配置变更，返回的句柄在该事件被处理(或超时)后关闭
+// 同一个快照只会入队一次，避免注册和更新并发时重复投递
+func (e *listenerEntry[T]) enqueue(snapshot *T) *listenerWaiter {
+	waiter := e.newWaiter()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		waiter.finish(ListenerDropped, 0)
+		return waiter
+	}
+	if snapshot == e.last {
+		waiter.finish(ListenerFiltered, 0)
+		return waiter
+	}
+	if e.pending == nil {
+		e.pending = &pendingEvent[T]{old: e.last}
+	}
+	e.pending.new = snapshot
+	e.pending.waiters = append(e.pending.waiters, waiter)
+	e.last = snapshot
+
+	// 同步等待者的期限从入队开始计算：前一次回调超时后仍卡住时，
+	// 排在它后面的事件到期同样按超时放行，不会让更新一直等下去
+	if e.options.sync {
+		timeout := e.options.timeout
+		time.AfterFunc(timeout, func() { waiter.finish(ListenerTimeout, timeout) })
+	}
+
+	select {
+	case e.wakeup <- struct{}{}:
+	default:
+	}
+	return waiter
+}
+
+// newWaiter 创建等待句柄
+func (e *listenerEntry[T]) newWaiter() *listenerWaiter {
+	return &listenerWaiter{
+		done:    make(chan struct{}),
+		outcome: ListenerOutcome{Listener: e.name(), Sync: e.options.sync},
+	}
+}
+
+// finish 记录结果并唤醒等待者，只有第一次调用生效
+func (w *listenerWaiter) finish(result string, elapsed time.Duration) {
+	w.once.Do(func() {
+		w.outcome.Result = result
+		w.outcome.DurationMs = float64(elapsed) / float64(time.Millisecond)
+		close(w.done)
+	})
+}
+
+// prime 首次加载配置时调用：设置差异计算的起点，WithReplay的监听器直接收到首份配置
+func (e *listenerEntry[T]) prime(snapshot *T) {
+	if e.options.replay {
+		e.enqueue(snapshot)
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.last == nil && e.pending == nil {
+		e.last = snapshot
+	}
+}
+
+// close 停止投递goroutine，尚未投递的事件直接丢弃并唤醒等待者
+func (e *listenerEntry[T]) close() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.closed = true
+	if e.pending != nil {
+		for _, w := range e.pending.waiters {
+			w.finish(ListenerDropped, 0)
+		}
+		e.pending = nil
+	}
+	close(e.wakeup)
+}
+
+// run 投递goroutine，逐个处理合并后的事件
+func (e *listenerEntry[T]) run() {
+	for range e.wakeup {
+		e.mutex.Lock()
+		p := e.pending
+		e.pending = nil
+		e.mutex.Unlock()
+
+		if p != nil {
+			e.deliver(p)
+		}
+	}
+}
+
+// deliver 调用监听器并唤醒等待者
+func (e *listenerEntry[T]) deliver(p *pendingEvent[T]) {
+	started := time.Now()
+	released := false
+	release := func(result string) {
+		if released {
+			return
+		}
+		released = true
+		elapsed := time.Since(started)
+		for _, w := range p.waiters {
+			w.finish(result, elapsed)
+		}
+	}
+
+	// 合并后重新计算差异，A->B->A 这种来回变化不需要通知
+	diff := DiffConfig(p.old, p.new)
+	if diff.Empty() || !diff.Matches(e.options.paths...) {
+		release(ListenerFiltered)
+		return
+	}
+
+	event := &ConfigChangeEvent[T]{Old: deepCopy(p.old), New: deepCopy(p.new), Diff: diff}
+	done := make(chan struct{})
+	result := ListenerOK
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				result = ListenerPanic
+				e.report(&ListenerPanicError{Listener: e.name(), Value: r})
+			}
+		}()
+		e.listener.OnConfigChange(event)
+	}()
+
+	if e.options.timeout <= 0 {
+		<-done
+		release(result)
+		return
+	}
+
+	timer := time.NewTimer(e.options.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		release(result)
+	case <-timer.C:
+		e.report(&ListenerTimeoutError{Listener: e.name(), Timeout: e.options.timeout})
+		// 超时后先放行等待者，但仍等本次回调结束再投递下一个事件，保证顺序；
+		// 这期间入队的同步等待者由各自的入队期限放行，发布方不会被这里阻塞
+		release(ListenerTimeout)
+		<-done
+	}
+}
+
+// name 监听器的可读名称，优先使用WithName指定的名称
+func (e *listenerEntry[T]) name() string {
+	if e.options.name != "" {
+		return e.options.name
+	}
+	return fmt.Sprintf("%T", e.listener)
+}
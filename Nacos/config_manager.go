@@ -5,212 +5,717 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/common/nacos_error"
 	"github.com/nacos-group/nacos-sdk-go/vo"
-	"gopkg.in/yaml.v2"
 )
 
 //生成级别的nacos配置管理器工具包
 
 // ConfigManager 配置管理器，负责配置的获取、监听和更新
-type ConfigManager struct {
-	client       config_client.IConfigClient //客户端
-	config       *ConfigData                 //配置信息
-	mutex        sync.RWMutex                //读写锁
-	waitGroup    sync.WaitGroup              //等待组
-	stopChan     chan struct{}               //停止通道
-	listeners    []ConfigChangeListener      //监听器
-	initialized  bool                        //是否初始化
-	configParams vo.ConfigParam              //配置参数
+// T 为业务自定义的配置结构体，每个服务可以把自己的结构体绑定到一个dataId/group上
+type ConfigManager[T any] struct {
+	client          config_client.IConfigClient       //客户端
+	config          atomic.Pointer[T]                 //配置快照，每次更新整体替换，读取时无需加锁
+	mutex           sync.RWMutex                      //读写锁，保护监听器和解码器等可变状态
+	updateMutex     sync.Mutex                        //串行化配置更新，保证各监听器看到的变更顺序一致
+	waitGroup       sync.WaitGroup                    //等待组，跟踪后台goroutine
+	stopChan        chan struct{}                     //停止通道，每次Start重新创建
+	lifecycleMutex  sync.Mutex                        //串行化Start/Stop
+	generation      atomic.Uint64                     //监听代次，Stop后旧回调失效
+	listeners       []*listenerEntry[T]               //监听器，写时复制，投递时可以安全地并发注册/注销
+	nextID          uint64                            //下一个监听器编号
+	running         bool                              //是否正在运行，受lifecycleMutex保护
+	layers          []vo.ConfigParam                  //配置来源，按优先级从低到高排列，后面的覆盖前面的
+	contents        []string                          //各层当前生效的原文，受updateMutex保护
+	decoder         ConfigDecoder                     //自定义解码器，为空时按配置类型从注册表查找
+	validators      []ConfigValidator[T]              //自定义校验函数
+	errListeners    []ConfigErrorListener             //错误监听器
+	snapshotPath    string                            //本地快照文件路径，为空表示不落盘
+	status          atomic.Pointer[ConfigStatus]      //当前配置的来源、是否过期等元信息
+	overrides       []ConfigOverride                  //覆盖层，按添加顺序生效
+	sources         atomic.Pointer[map[string]string] //各字段当前值的来源
+	secretProviders []SecretProvider                  //密钥提供者，解析${secret:...}占位符
+	encryptionKey   []byte                            //解密ENC(...)的AES密钥
+	history         []historyEntry[T]                 //已生效的历史版本，按版本号从旧到新
+	historySize     int                               //保留的历史版本数，0表示使用默认值
+	lastVersion     int                               //最近一次分配的版本号
+	applied         chan struct{}                     //下一次配置生效时关闭，用于等待回滚生效
+	namespace       string                            //审计事件中记录的命名空间
+	auditSinks      []AuditSink                       //审计事件的输出
+	auditTail       chan struct{}                     //最近一个审计事件写出后关闭，受updateMutex保护
 }
 
-// ConfigChangeListener 配置变更监听器接口
-type ConfigChangeListener interface {
-	OnConfigChange(config *ConfigData)
+// ConfigChangeListener 配置变更监听器接口，事件中包含新旧快照和字段级差异
+type ConfigChangeListener[T any] interface {
+	OnConfigChange(event *ConfigChangeEvent[T])
+}
+
+// ConfigChangeListenerFunc 函数形式的配置变更监听器
+type ConfigChangeListenerFunc[T any] func(event *ConfigChangeEvent[T])
+
+// OnConfigChange 实现ConfigChangeListener接口
+func (f ConfigChangeListenerFunc[T]) OnConfigChange(event *ConfigChangeEvent[T]) {
+	f(event)
+}
+
+// Stoppable 可以被优雅关闭的组件，ctx携带关闭的截止时间
+type Stoppable interface {
+	Stop(ctx context.Context) error
 }
 
 // NewConfigManager 创建配置管理器实例
-func NewConfigManager(client config_client.IConfigClient, dataId, group, configType string) *ConfigManager {
-	return &ConfigManager{
+func NewConfigManager[T any](client config_client.IConfigClient, dataId, group, configType string) *ConfigManager[T] {
+	return NewLayeredConfigManager[T](client, vo.ConfigParam{
+		DataId: dataId,
+		Group:  group,
+		Type:   vo.ConfigType(configType),
+	})
+}
+
+// NewLayeredConfigManager 创建多层配置管理器，同时监听多个dataId并按顺序深度合并
+// layers 按优先级从低到高排列，例如 公共配置 -> 环境配置 -> 实例覆盖配置；
+// 任意一层变化都会重新合并，合并结果真正变化时监听器只收到一次通知
+func NewLayeredConfigManager[T any](client config_client.IConfigClient, layers ...vo.ConfigParam) *ConfigManager[T] {
+	if len(layers) == 0 {
+		panic("nacos: NewLayeredConfigManager至少需要一个配置来源")
+	}
+	return &ConfigManager[T]{
 		client:    client,
-		config:    &ConfigData{},
-		stopChan:  make(chan struct{}),
-		listeners: make([]ConfigChangeListener, 0),
-		configParams: vo.ConfigParam{
-			DataId: dataId,
-			Group:  group,
-			Type:   vo.ConfigType(configType),
-		},
+		listeners: make([]*listenerEntry[T], 0),
+		layers:    append([]vo.ConfigParam(nil), layers...),
 	}
 }
 
-// Start 启动配置管理器
-func (cm *ConfigManager) Start() error {
-	if cm.initialized {
-		return fmt.Errorf("配置管理器已经初始化")
+// Start 启动配置管理器：加载初始配置并注册Nacos监听
+// 重复调用是幂等的；Stop之后可以再次Start；ctx用于控制初始配置加载的超时
+func (cm *ConfigManager[T]) Start(ctx context.Context) error {
+	cm.lifecycleMutex.Lock()
+	defer cm.lifecycleMutex.Unlock()
+
+	if cm.running {
+		return nil
 	}
 
-	// 首先获取初始配置
-	err := cm.loadInitialConfig()
+	// 首先获取初始配置，Nacos不可用时退回到本地快照
+	stale := false
+	err := cm.loadInitialConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("加载初始配置失败: %v", err)
+		cm.reportError(err)
+		if fallbackErr := cm.loadSnapshotFile(); fallbackErr != nil {
+			return fmt.Errorf("加载初始配置失败: %v, 本地快照也不可用: %v", err, fallbackErr)
+		}
+		logf("Nacos暂不可用(%v)，已使用本地快照启动，配置可能已过期\n", err)
+		stale = true
+	}
+
+	// 每次启动使用新的代次，旧代次的Nacos回调到达时直接丢弃
+	generation := cm.generation.Add(1)
+	for i := range cm.layers {
+		err = cm.client.ListenConfig(cm.listenParam(generation, i))
+		if err != nil {
+			cm.generation.Add(1)
+			cm.cancelListen(i)
+			cm.reportError(err)
+			return fmt.Errorf("启动配置监听失败: %v", err)
+		}
 	}
 
-	// 在单独的goroutine中启动配置监听
-	cm.waitGroup.Add(1)
-	go cm.listenForChanges()
+	cm.stopChan = make(chan struct{})
+	cm.running = true
 
-	cm.initialized = true
-	fmt.Println("配置管理器已成功启动")
+	// 使用本地快照启动时，在后台持续重试，直到拿到Nacos上的最新配置
+	if stale {
+		cm.waitGroup.Add(1)
+		go cm.retryLiveConfig(cm.stopChan, generation)
+	}
+	logf("配置管理器已成功启动\n")
 	return nil
 }
 
-// loadInitialConfig 加载初始配置
-func (cm *ConfigManager) loadInitialConfig() error {
-	content, err := cm.client.GetConfig(cm.configParams)
-	if err != nil {
-		return err
+// SetDecoder 为当前管理器指定解码器，覆盖按配置类型查找的默认解码器
+func (cm *ConfigManager[T]) SetDecoder(decoder ConfigDecoder) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.decoder = decoder
+}
+
+// SetSnapshotFile 设置本地快照文件路径，每次成功应用Nacos配置后都会写入该文件，
+// 启动时Nacos不可用则从该文件加载配置
+func (cm *ConfigManager[T]) SetSnapshotFile(path string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.snapshotPath = path
+}
+
+// Status 返回当前配置的来源和是否过期
+func (cm *ConfigManager[T]) Status() ConfigStatus {
+	status := cm.status.Load()
+	if status == nil {
+		return ConfigStatus{}
 	}
+	return *status
+}
+
+// loadInitialConfig 加载初始配置，ctx结束时放弃等待
+func (cm *ConfigManager[T]) loadInitialConfig(ctx context.Context) error {
+	type result struct {
+		contents []string
+		err      error
+	}
+	// SDK的GetConfig不支持context，放到goroutine中执行以便响应超时
+	resultChan := make(chan result, 1)
+	go func() {
+		contents, err := cm.fetchLayers()
+		resultChan <- result{contents: contents, err: err}
+	}()
 
-	return cm.updateConfig(content)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case r := <-resultChan:
+		if r.err != nil {
+			return r.err
+		}
+		return cm.updateConfig(r.contents, SourceNacos)
+	}
 }
 
-// listenForChanges 监听配置变化
-func (cm *ConfigManager) listenForChanges() {
-	defer cm.waitGroup.Done()
+// fetchLayers 从Nacos拉取所有层的配置原文
+// SDK的GetConfig在请求Nacos失败时会静默退回到它自己的缓存文件并且不返回错误，
+// 因此拉取之后再确认一次Nacos可达，不可达时按失败处理，由本地快照兜底并标记为过期
+func (cm *ConfigManager[T]) fetchLayers() ([]string, error) {
+	contents := make([]string, len(cm.layers))
+	for i, layer := range cm.layers {
+		content, err := cm.client.GetConfig(layer)
+		if err != nil {
+			return nil, fmt.Errorf("获取配置[%s/%s]失败: %v", layer.Group, layer.DataId, err)
+		}
+		contents[i] = content
+	}
+	if err := cm.checkReachable(cm.layers[0]); err != nil {
+		return nil, fmt.Errorf("Nacos不可达，获取到的配置可能来自SDK缓存: %v", err)
+	}
+	return contents, nil
+}
+
+// checkReachable 用不读缓存的SearchConfig精确查询一次，确认Nacos可达
+// 服务端返回了错误码(例如404)同样说明可达，只有网络层失败才算不可达
+func (cm *ConfigManager[T]) checkReachable(layer vo.ConfigParam) error {
+	_, err := cm.client.SearchConfig(vo.SearchConfigParam{
+		Search:   "accurate",
+		DataId:   layer.DataId,
+		Group:    layer.Group,
+		PageNo:   1,
+		PageSize: 1,
+	})
+	if _, ok := err.(*nacos_error.NacosError); ok {
+		return nil
+	}
+	return err
+}
 
-	// 启动Nacos监听
-	err := cm.client.ListenConfig(vo.ConfigParam{
-		DataId: cm.configParams.DataId,
-		Group:  cm.configParams.Group,
-		Type:   cm.configParams.Type,
+// listenParam 构造第index层的Nacos监听参数，回调只在对应代次仍然有效时才处理
+func (cm *ConfigManager[T]) listenParam(generation uint64, index int) vo.ConfigParam {
+	layer := cm.layers[index]
+	return vo.ConfigParam{
+		DataId: layer.DataId,
+		Group:  layer.Group,
+		Type:   layer.Type,
 		OnChange: func(namespace, group, dataId, data string) {
-			fmt.Println("检测到配置变更，正在更新...")
-			err := cm.updateConfig(data)
+			if cm.generation.Load() != generation {
+				return
+			}
+			logf("检测到配置[%s/%s]变更，正在更新...\n", group, dataId)
+			err := cm.updateLayer(index, data, SourceNacos)
 			if err != nil {
-				fmt.Printf("配置更新失败: %v\n", err)
+				logf("配置更新失败: %v\n", err)
+				cm.reportError(err)
 				return
 			}
-
-			// 通知所有监听器
-			cm.notifyListeners()
-			fmt.Println("配置已成功更新并通知所有监听器")
+			logf("配置已成功更新并通知所有监听器\n")
 		},
-	})
+	}
+}
 
+// cancelListen 注销前n层的Nacos监听
+func (cm *ConfigManager[T]) cancelListen(n int) {
+	for _, layer := range cm.layers[:n] {
+		err := cm.client.CancelListenConfig(vo.ConfigParam{
+			DataId: layer.DataId,
+			Group:  layer.Group,
+		})
+		if err != nil {
+			cm.reportError(err)
+			logf("注销配置[%s/%s]监听失败: %v\n", layer.Group, layer.DataId, err)
+		}
+	}
+}
+
+// updateLayer 第index层配置变化，与其他层重新合并后生效
+func (cm *ConfigManager[T]) updateLayer(index int, content, source string) error {
+	cm.updateMutex.Lock()
+	defer cm.updateMutex.Unlock()
+
+	contents := make([]string, len(cm.layers))
+	copy(contents, cm.contents)
+	contents[index] = content
+	return cm.applyContents(contents, source)
+}
+
+// updateConfig 使用全部层的原文更新配置并通知监听器，source为配置来源
+func (cm *ConfigManager[T]) updateConfig(contents []string, source string) error {
+	cm.updateMutex.Lock()
+	defer cm.updateMutex.Unlock()
+
+	return cm.applyContents(contents, source)
+}
+
+// applyContents 解码、校验并发布新配置，调用方需持有updateMutex
+// 快照一旦发布就不再修改，对外只暴露它的深拷贝
+// 无论生效还是被拒绝，都会生成一条审计事件
+func (cm *ConfigManager[T]) applyContents(contents []string, source string) error {
+	started := time.Now()
+	audit := cm.newAuditEvent(contents, source)
+	newConfig, sources, secrets, err := cm.parseConfig(contents, source)
 	if err != nil {
-		fmt.Printf("启动配置监听失败: %v\n", err)
+		audit.reject(err, time.Since(started))
+		cm.emitAudit(audit, nil)
+		return err
+	}
+	cm.contents = contents
+	cm.sources.Store(&sources)
+	replaceSecretValues(cm, secrets)
+
+	diff, waiters := cm.publish(newConfig)
+	cm.recordApplied(contents, source, newConfig)
+
+	audit.apply(cm.Status().Version, diff, time.Since(started))
+	cm.emitAudit(audit, waiters)
+	return nil
+}
+
+// publish 发布新快照并通知监听器，返回与旧快照的字段差异和各监听器的投递句柄
+func (cm *ConfigManager[T]) publish(newConfig *T) (ConfigDiff, []*listenerWaiter) {
+	// 首次加载没有旧配置，只记录差异起点，不触发通知
+	old := cm.config.Load()
+	if old == nil {
+		cm.config.Store(newConfig)
+		cm.primeListeners(newConfig)
+		return DiffConfig(old, newConfig), nil
+	}
+	diff := DiffConfig(old, newConfig)
+	if diff.Empty() {
+		// 仍然发布新快照，避免差异计算遗漏的字段变化被丢弃
+		cm.config.Store(newConfig)
+		logf("配置内容没有变化，跳过通知\n")
+		return diff, nil
+	}
+
+	// 同步监听器处理完后才发布新快照，异步监听器在发布之后通知
+	waiters := cm.notifyListeners(newConfig, true)
+	cm.config.Store(newConfig)
+	return diff, append(waiters, cm.notifyListeners(newConfig, false)...)
+}
+
+// recordApplied 记录配置元信息和历史版本，来自Nacos的配置同时写入本地快照
+func (cm *ConfigManager[T]) recordApplied(contents []string, source string, config *T) {
+	md5sum := layersMD5(contents)
+	cm.status.Store(&ConfigStatus{
+		Source:   source,
+		Stale:    source != SourceNacos,
+		MD5:      md5sum,
+		Version:  cm.recordHistory(contents, source, md5sum, config),
+		LoadedAt: time.Now(),
+	})
+	cm.signalApplied()
+
+	cm.mutex.RLock()
+	path := cm.snapshotPath
+	cm.mutex.RUnlock()
+	if path == "" || source != SourceNacos {
 		return
 	}
 
-	// 等待停止信号
-	select {
-	case <-cm.stopChan:
-		fmt.Println("收到停止信号，配置监听将停止")
+	snapshot := configSnapshotFile{MD5: md5sum, SavedAt: time.Now()}
+	for i, layer := range cm.layers {
+		snapshot.Layers = append(snapshot.Layers, snapshotLayer{
+			DataId:  layer.DataId,
+			Group:   layer.Group,
+			Type:    string(layer.Type),
+			MD5:     contentMD5(contents[i]),
+			Content: contents[i],
+		})
+	}
+	if err := writeSnapshotFile(path, snapshot); err != nil {
+		// 落盘失败不影响配置生效，只上报错误
+		logf("写入本地快照失败: %v\n", err)
+		cm.reportError(err)
 	}
 }
 
-// updateConfig 更新配置内容
-func (cm *ConfigManager) updateConfig(content string) error {
-	var newConfig ConfigData
-	err := yaml.Unmarshal([]byte(content), &newConfig)
+// loadSnapshotFile 从本地快照文件加载配置
+func (cm *ConfigManager[T]) loadSnapshotFile() error {
+	cm.mutex.RLock()
+	path := cm.snapshotPath
+	cm.mutex.RUnlock()
+	if path == "" {
+		return fmt.Errorf("未配置本地快照文件")
+	}
+
+	snapshot, err := readSnapshotFile(path)
 	if err != nil {
-		return fmt.Errorf("YAML解析失败: %v, 内容: %s", err, content)
+		return err
+	}
+	if len(snapshot.Layers) != len(cm.layers) {
+		return fmt.Errorf("快照文件%s包含%d层配置，与当前的%d层不匹配", path, len(snapshot.Layers), len(cm.layers))
+	}
+	contents := make([]string, len(cm.layers))
+	for i, layer := range cm.layers {
+		saved := snapshot.Layers[i]
+		if saved.DataId != layer.DataId || saved.Group != layer.Group {
+			return fmt.Errorf("快照文件%s第%d层属于[%s/%s]，与当前配置[%s/%s]不匹配",
+				path, i+1, saved.Group, saved.DataId, layer.Group, layer.DataId)
+		}
+		contents[i] = saved.Content
 	}
+	return cm.updateConfig(contents, SourceLocalSnapshot)
+}
+
+// retryLiveConfig 使用本地快照启动后，按指数退避重试从Nacos拉取配置，成功后升级为实时配置
+func (cm *ConfigManager[T]) retryLiveConfig(stopChan <-chan struct{}, generation uint64) {
+	defer cm.waitGroup.Done()
 
-	// 加锁更新配置
+	interval := snapshotRetryInitial
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// 期间可能已经通过Nacos推送拿到了实时配置
+		if !cm.Status().Stale || cm.generation.Load() != generation {
+			return
+		}
+
+		contents, err := cm.fetchLayers()
+		if err == nil {
+			err = cm.updateConfig(contents, SourceNacos)
+			if err == nil {
+				logf("已重新连接Nacos，配置已更新为最新版本\n")
+				return
+			}
+		}
+		logf("从Nacos拉取配置失败，%s后重试: %v\n", interval, err)
+
+		interval *= 2
+		if interval > snapshotRetryMax {
+			interval = snapshotRetryMax
+		}
+	}
+}
+
+// parseConfig 解码、合并各层配置内容，应用覆盖层后进行校验
+// 同时返回每个字段值的来源和需要脱敏的明文，source为本次配置的来源(nacos或本地快照)
+func (cm *ConfigManager[T]) parseConfig(contents []string, source string) (*T, map[string]string, []string, error) {
+	newConfig := new(T)
+	sources := make(map[string]string)
+	if len(cm.layers) == 1 {
+		// 单层配置直接解码，支持text等无法合并的格式
+		layer := cm.layers[0]
+		decoder, err := cm.resolveDecoder(layer.Type)
+		if err != nil {
+			return nil, nil, nil, cm.rejected("decode", err)
+		}
+		err = decoder.Decode([]byte(contents[0]), newConfig)
+		if err != nil {
+			return nil, nil, nil, cm.rejected("decode", fmt.Errorf("%s解析失败: %v, 内容: %s", normalizeConfigType(string(layer.Type)), err, RedactContent(contents[0])))
+		}
+		// 再按通用结构解码一次，仅用于记录出现了哪些字段；text等格式解不出来时忽略
+		var values map[string]interface{}
+		if decoder.Decode([]byte(contents[0]), &values) == nil {
+			if normalized, ok := normalizeValue(values).(map[string]interface{}); ok {
+				recordLayerSources(normalized, "", layerSource(source, layer), sources)
+			}
+		}
+	} else {
+		merged, err := cm.mergeLayers(contents, source, sources)
+		if err != nil {
+			return nil, nil, nil, cm.rejected("decode", err)
+		}
+		if err := bindMerged(merged, newConfig); err != nil {
+			return nil, nil, nil, cm.rejected("decode", err)
+		}
+	}
+
+	// 部署时的环境变量、命令行参数覆盖Nacos上的值
+	if err := cm.applyOverrides(newConfig, sources); err != nil {
+		return nil, nil, nil, cm.rejected("override", err)
+	}
+
+	// 覆盖完成后再解析密钥，环境变量中同样可以使用占位符和加密值
+	secrets, err := cm.resolveSecrets(newConfig)
+	if err != nil {
+		return nil, nil, nil, cm.rejected("secret", err)
+	}
+
+	// 校验不通过时直接拒绝，保留上一份有效配置
+	cm.mutex.RLock()
+	validators := cm.validators
+	cm.mutex.RUnlock()
+	if err := validateConfig(newConfig, validators); err != nil {
+		return nil, nil, nil, cm.rejected("validate", err)
+	}
+	return newConfig, sources, secrets, nil
+}
+
+// rejected 构造配置被拒绝的错误，多层配置时dataId和group用逗号拼接
+func (cm *ConfigManager[T]) rejected(stage string, err error) error {
+	dataIds := make([]string, len(cm.layers))
+	groups := make([]string, len(cm.layers))
+	for i, layer := range cm.layers {
+		dataIds[i] = layer.DataId
+		groups[i] = layer.Group
+	}
+	return &ConfigRejectedError{
+		DataId: strings.Join(dataIds, ","),
+		Group:  strings.Join(groups, ","),
+		Stage:  stage,
+		Err:    err,
+	}
+}
+
+// resolveDecoder 优先使用自定义解码器，否则按配置类型从注册表查找
+func (cm *ConfigManager[T]) resolveDecoder(configType vo.ConfigType) (ConfigDecoder, error) {
+	cm.mutex.RLock()
+	decoder := cm.decoder
+	cm.mutex.RUnlock()
+
+	if decoder != nil {
+		return decoder, nil
+	}
+	return LookupDecoder(string(configType))
+}
+
+// GetConfig 获取当前配置
+func (cm *ConfigManager[T]) GetConfig() *T {
+	snapshot := cm.config.Load()
+	if snapshot == nil {
+		return new(T)
+	}
+
+	// 返回配置的深拷贝，避免外部修改切片、map等共享数据
+	return deepCopy(snapshot)
+}
+
+// AddListener 添加配置变更监听器，返回的订阅句柄可用于取消订阅
+// 可以通过 WithPaths("database.*") 只订阅部分字段，只有这些字段变化时才会收到通知；
+// WithSync() 让新配置等该监听器处理完才对外可见，WithTimeout() 限制单次回调耗时；
+// WithReplay() 在注册时立即收到当前配置；WithName() 指定审计事件和指标中的监听器名称
+func (cm *ConfigManager[T]) AddListener(listener ConfigChangeListener[T], opts ...ListenerOption) *Subscription {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
-	*cm.config = newConfig
-	return nil
+	cm.nextID++
+	entry := newListenerEntry(cm.nextID, listener, opts, cm.reportError, cm.config.Load())
+
+	// 写时复制，正在投递的goroutine持有的旧切片不受影响
+	listeners := make([]*listenerEntry[T], 0, len(cm.listeners)+1)
+	listeners = append(listeners, cm.listeners...)
+	cm.listeners = append(listeners, entry)
+
+	return &Subscription{id: entry.id, cancel: cm.removeListener}
 }
 
-// GetConfig 获取当前配置
-func (cm *ConfigManager) GetConfig() *ConfigData {
-	cm.mutex.RLock()
-	defer cm.mutex.RUnlock()
+// RemoveListener 注销监听器，返回该监听器此前是否处于注册状态
+// 正在执行中的回调会继续执行完，尚未投递的变更会被丢弃
+func (cm *ConfigManager[T]) RemoveListener(subscription *Subscription) bool {
+	if subscription == nil {
+		return false
+	}
+	return cm.removeListener(subscription.id)
+}
+
+// removeListener 按编号注销监听器
+func (cm *ConfigManager[T]) removeListener(id uint64) bool {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
 
-	// 返回配置的副本，避免外部修改
-	configCopy := *cm.config
-	return &configCopy
+	for i, entry := range cm.listeners {
+		if entry.id != id {
+			continue
+		}
+		listeners := make([]*listenerEntry[T], 0, len(cm.listeners)-1)
+		listeners = append(listeners, cm.listeners[:i]...)
+		cm.listeners = append(listeners, cm.listeners[i+1:]...)
+		entry.close()
+		return true
+	}
+	return false
 }
 
-// AddListener 添加配置变更监听器
-func (cm *ConfigManager) AddListener(listener ConfigChangeListener) {
+// AddValidator 添加自定义配置校验函数，在新配置生效前执行
+func (cm *ConfigManager[T]) AddValidator(validator ConfigValidator[T]) {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
-	cm.listeners = append(cm.listeners, listener)
+	cm.validators = append(cm.validators, validator)
 }
 
-// notifyListeners 通知所有监听器配置已变更
-func (cm *ConfigManager) notifyListeners() {
-	// 获取配置副本
-	config := cm.GetConfig()
+// AddErrorListener 添加错误监听器，配置被拒绝时会收到通知
+func (cm *ConfigManager[T]) AddErrorListener(listener ConfigErrorListener) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
 
-	// 在goroutine中通知每个监听器，避免阻塞
-	for _, listener := range cm.listeners {
-		go func(l ConfigChangeListener) {
+	cm.errListeners = append(cm.errListeners, listener)
+}
+
+// reportError 把错误同步通知给所有错误监听器
+func (cm *ConfigManager[T]) reportError(err error) {
+	cm.mutex.RLock()
+	listeners := cm.errListeners
+	cm.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					fmt.Printf("监听器处理配置变更时发生panic: %v\n", r)
+					logf("错误监听器处理时发生panic: %v\n", r)
 				}
 			}()
-			l.OnConfigChange(config)
-		}(listener)
+			listener.OnConfigError(err)
+		}()
 	}
 }
 
-// Stop 停止配置管理器
-func (cm *ConfigManager) Stop() {
-	if !cm.initialized {
-		return
+// primeListeners 首份配置生效时通知监听器记录差异起点
+func (cm *ConfigManager[T]) primeListeners(snapshot *T) {
+	cm.mutex.RLock()
+	listeners := cm.listeners
+	cm.mutex.RUnlock()
+
+	for _, entry := range listeners {
+		entry.prime(snapshot)
 	}
+}
+
+// notifyListeners 通知同步或异步的监听器配置已变更，返回各监听器的投递句柄
+// 每个监听器有自己的投递队列，保证按顺序收到变更，并且拿到独立的深拷贝
+// 同步模式下会等待所有同步监听器处理完成(或超时)后再返回
+func (cm *ConfigManager[T]) notifyListeners(snapshot *T, synchronous bool) []*listenerWaiter {
+	cm.mutex.RLock()
+	listeners := cm.listeners
+	cm.mutex.RUnlock()
+
+	var waiters []*listenerWaiter
+	for _, entry := range listeners {
+		if entry.options.sync != synchronous {
+			continue
+		}
+		waiters = append(waiters, entry.enqueue(snapshot))
+	}
+	if synchronous {
+		for _, waiter := range waiters {
+			<-waiter.done
+		}
+	}
+	return waiters
+}
+
+// Stop 停止配置管理器：注销Nacos监听并等待后台任务结束
+// 重复调用是幂等的；ctx到期时不再等待后台任务，返回ctx的错误；
+// 已注册的监听器会保留，再次Start后继续收到通知
+func (cm *ConfigManager[T]) Stop(ctx context.Context) error {
+	cm.lifecycleMutex.Lock()
+	defer cm.lifecycleMutex.Unlock()
+
+	if !cm.running {
+		return nil
+	}
+	cm.running = false
+
+	// 先让旧代次的回调失效，再注销Nacos监听
+	cm.generation.Add(1)
+	cm.cancelListen(len(cm.layers))
 
 	// 发送停止信号
 	close(cm.stopChan)
 
-	// 等待监听goroutine结束
-	cm.waitGroup.Wait()
+	// 等待后台goroutine结束，或者ctx到期
+	done := make(chan struct{})
+	go func() {
+		cm.waitGroup.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("等待配置管理器停止超时: %w", ctx.Err())
+	}
+
+	// 等待正在进行的配置更新完成，保证Stop返回后不会再有新的通知；
+	// 同时注销本管理器登记的脱敏明文，再次Start时会重新登记
+	cm.updateMutex.Lock()
+	removeSecretValues(cm)
+	cm.updateMutex.Unlock()
 
-	cm.initialized = false
-	fmt.Println("配置管理器已成功停止")
+	logf("配置管理器已成功停止\n")
+	return nil
 }
 
 //上面的时包内的工具，下面的时外部调用的接口
 
 // ExampleService 使用配置的示例服务
 type ExampleService struct {
-	configManager *ConfigManager
+	configManager *ConfigManager[ConfigData]
 }
 
 // NewExampleService 创建示例服务
-func NewExampleService(configManager *ConfigManager) *ExampleService {
+func NewExampleService(configManager *ConfigManager[ConfigData]) *ExampleService {
 	service := &ExampleService{
 		configManager: configManager,
 	}
 
-	// 注册为配置变更监听器
+	// 注册为配置变更监听器和错误监听器
 	configManager.AddListener(service)
+	configManager.AddErrorListener(service)
 	return service
 }
 
 // OnConfigChange 实现ConfigChangeListener接口
-func (s *ExampleService) OnConfigChange(config *ConfigData) {
-	fmt.Println("\nExampleService检测到配置更新:")
-	fmt.Printf("  应用名称: %s\n", config.AppName)
-	fmt.Printf("  服务器端口: %d\n", config.ServerPort)
-	fmt.Printf("  数据库URL: %s\n", config.Database.Url)
-	fmt.Printf("  数据库用户名: %s\n", config.Database.Username)
-	fmt.Printf("  数据库密码: ********\n") // 不显示密码明文
-	fmt.Printf("  功能列表: %v\n", config.Features)
+func (s *ExampleService) OnConfigChange(event *ConfigChangeEvent[ConfigData]) {
+	config := event.New
+	logf("\nExampleService检测到配置更新:\n")
+	logf("  变更字段: %v\n", event.Diff.Paths())
+	logf("  应用名称: %s\n", config.AppName)
+	logf("  服务器端口: %d\n", config.ServerPort)
+	logf("  数据库URL: %s\n", config.Database.Url)
+	logf("  数据库用户名: %s\n", config.Database.Username)
+	logf("  数据库密码: ********\n") // 不显示密码明文
+	logf("  功能列表: %v\n", config.Features)
+	logf("  功能开关: %v\n", config.Flags)
 
 	// 在这里可以进行服务重启、资源重新初始化等操作
+	// 数据库连接池交给DBPool，它会订阅同一个配置管理器并自动切换
+	if event.Diff.Matches("database") {
+		logf("  数据库配置发生变化，DBPool会在新连接池可用后自动切换\n")
+	}
+}
+
+// OnConfigError 实现ConfigErrorListener接口，配置被拒绝时继续使用旧配置
+func (s *ExampleService) OnConfigError(err error) {
+	logf("\nExampleService收到配置错误，继续使用当前配置: %v\n", err)
 }
 
 // GetConfig 获取当前配置
@@ -218,8 +723,18 @@ func (s *ExampleService) GetConfig() *ConfigData {
 	return s.configManager.GetConfig()
 }
 
-// SetupGracefulShutdown 设置优雅关闭
-func SetupGracefulShutdown(managers ...*ConfigManager) context.Context {
+// defaultShutdownTimeout SetupGracefulShutdown关闭全部组件的总时长
+const defaultShutdownTimeout = 5 * time.Second
+
+// SetupGracefulShutdown 设置优雅关闭：收到中断信号后按传入顺序依次关闭组件，全部组件共用5秒的关闭时长，
+// ServiceLifecycle禁用实例后的等待(默认3秒)也计入其中；需要更长时间时使用SetupGracefulShutdownTimeout
+func SetupGracefulShutdown(managers ...Stoppable) context.Context {
+	return SetupGracefulShutdownTimeout(defaultShutdownTimeout, managers...)
+}
+
+// SetupGracefulShutdownTimeout 设置优雅关闭，timeout是全部组件共用的关闭时长，应不小于各组件关闭时间之和；
+// 到期后正在关闭的组件收到ctx取消，排在后面的组件拿到的是已经到期的ctx
+func SetupGracefulShutdownTimeout(timeout time.Duration, managers ...Stoppable) context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// 监听系统信号
@@ -231,23 +746,29 @@ func SetupGracefulShutdown(managers ...*ConfigManager) context.Context {
 
 	go func() {
 		<-c
-		fmt.Println("\n接收到中断信号，开始优雅关闭...")
+		logf("\n接收到中断信号，开始优雅关闭...\n")
 
 		// 创建关闭上下文，设置超时
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
 		defer shutdownCancel()
 
 		// 停止所有配置管理器
+		failed := false
 		for _, manager := range managers {
-			manager.Stop()
+			if err := manager.Stop(shutdownCtx); err != nil {
+				logf("组件关闭失败: %v\n", err)
+				failed = true
+			}
 		}
 
-		// 等待关闭完成或超时
-		select {
-		case <-shutdownCtx.Done():
-			fmt.Println("关闭超时，强制退出")
+		// 检查关闭是否完成或超时
+		switch {
+		case shutdownCtx.Err() != nil:
+			logf("关闭超时，强制退出\n")
+		case failed:
+			logf("部分组件关闭失败\n")
 		default:
-			fmt.Println("所有组件已成功关闭")
+			logf("所有组件已成功关闭\n")
 		}
 
 		cancel()
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+const (
+	testDataId = "app.yaml"
+	testGroup  = "test"
+)
+
+// appConfig 生成一份能通过ConfigData校验的yaml配置
+func appConfig(appName string, port int) string {
+	return fmt.Sprintf("appName: %s\nserverPort: %d\ndatabase:\n  url: mysql://localhost/app\n", appName, port)
+}
+
+// publishTestConfig 发布配置并等待回调执行完毕，返回时ConfigManager已经处理完这次变更
+func publishTestConfig(t *testing.T, client *FakeConfigClient, content string) {
+	t.Helper()
+	if _, err := client.PublishConfig(vo.ConfigParam{DataId: testDataId, Group: testGroup, Content: content}); err != nil {
+		t.Fatalf("发布配置失败: %v", err)
+	}
+	client.Flush()
+}
+
+// startTestManager 创建并启动配置管理器，测试结束时停止
+func startTestManager(t *testing.T, client *FakeConfigClient) *ConfigManager[ConfigData] {
+	t.Helper()
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cm.Stop(context.Background()) })
+	return cm
+}
+
+// eventRecorder 把监听器收到的事件转发到channel
+type eventRecorder struct {
+	events chan *ConfigChangeEvent[ConfigData]
+}
+
+func newEventRecorder() *eventRecorder {
+	return &eventRecorder{events: make(chan *ConfigChangeEvent[ConfigData], 64)}
+}
+
+// OnConfigChange 实现ConfigChangeListener接口
+func (r *eventRecorder) OnConfigChange(event *ConfigChangeEvent[ConfigData]) {
+	r.events <- event
+}
+
+// next 等待下一个事件
+func (r *eventRecorder) next(t *testing.T) *ConfigChangeEvent[ConfigData] {
+	t.Helper()
+	select {
+	case event := <-r.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待配置变更事件超时")
+		return nil
+	}
+}
+
+// none 确认一段时间内没有收到事件
+func (r *eventRecorder) none(t *testing.T) {
+	t.Helper()
+	select {
+	case event := <-r.events:
+		t.Fatalf("不应收到配置变更事件，实际收到: %v", event.Diff)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConfigManagerDecode(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("demo", 8080)+"features: [login, report]\n")
+	cm := startTestManager(t, client)
+
+	config := cm.GetConfig()
+	if config.AppName != "demo" || config.ServerPort != 8080 || config.Database.Url != "mysql://localhost/app" {
+		t.Fatalf("解码结果不正确: %+v", *config)
+	}
+	if len(config.Features) != 2 || config.Features[1] != "report" {
+		t.Errorf("features解码结果为%v", config.Features)
+	}
+	status := cm.Status()
+	if status.Source != SourceNacos || status.Stale || status.MD5 == "" {
+		t.Errorf("状态不正确: %+v", status)
+	}
+
+	// GetConfig返回深拷贝，修改不影响快照
+	config.Features[0] = "changed"
+	if cm.GetConfig().Features[0] != "login" {
+		t.Error("修改GetConfig的返回值影响了配置快照")
+	}
+
+	publishTestConfig(t, client, appConfig("demo", 9090))
+	if port := cm.GetConfig().ServerPort; port != 9090 {
+		t.Errorf("配置更新后端口为%d，期望9090", port)
+	}
+}
+
+func TestConfigManagerRejectsInvalidConfig(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("demo", 8080))
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	cm.AddValidator(func(config *ConfigData) error {
+		if config.AppName == "forbidden" {
+			return errors.New("appName不允许为forbidden")
+		}
+		return nil
+	})
+	rejected := make(chan error, 8)
+	cm.AddErrorListener(ConfigErrorListenerFunc(func(err error) { rejected <- err }))
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	t.Cleanup(func() { cm.Stop(context.Background()) })
+	recorder := newEventRecorder()
+	cm.AddListener(recorder)
+	good := cm.Status()
+
+	tests := []struct {
+		name    string
+		content string
+		stage   string
+	}{
+		{name: "语法错误", content: "appName: [demo\n", stage: "decode"},
+		{name: "标签校验", content: appConfig("demo", 70000), stage: "validate"},
+		{name: "自定义校验", content: appConfig("forbidden", 8080), stage: "validate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publishTestConfig(t, client, tt.content)
+
+			var err error
+			select {
+			case err = <-rejected:
+			case <-time.After(2 * time.Second):
+				t.Fatal("没有收到配置被拒绝的错误")
+			}
+			var rejectedErr *ConfigRejectedError
+			if !errors.As(err, &rejectedErr) || rejectedErr.Stage != tt.stage {
+				t.Fatalf("错误为%v，期望在%s阶段被拒绝", err, tt.stage)
+			}
+			if config := cm.GetConfig(); config.AppName != "demo" || config.ServerPort != 8080 {
+				t.Errorf("被拒绝后配置变成了%+v，期望保留上一份有效配置", *config)
+			}
+			if status := cm.Status(); status.MD5 != good.MD5 || status.Version != good.Version {
+				t.Errorf("被拒绝后状态变成了%+v，期望保持%+v", status, good)
+			}
+			recorder.none(t)
+		})
+	}
+
+	// 修正后恢复正常
+	publishTestConfig(t, client, appConfig("fixed", 8080))
+	if event := recorder.next(t); event.Old.AppName != "demo" || event.New.AppName != "fixed" {
+		t.Errorf("修正后的事件为%s -> %s", event.Old.AppName, event.New.AppName)
+	}
+}
+
+func TestConfigManagerListenerOrderingAndCoalescing(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("v0", 8080))
+	cm := startTestManager(t, client)
+
+	// 慢监听器处理第一个事件时阻塞，期间的变更应该合并成一次
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := newEventRecorder()
+	first := true
+	cm.AddListener(ConfigChangeListenerFunc[ConfigData](func(event *ConfigChangeEvent[ConfigData]) {
+		if first {
+			first = false
+			close(started)
+			<-release
+		}
+		slow.OnConfigChange(event)
+	}))
+	fast := newEventRecorder()
+	cm.AddListener(fast)
+
+	publishTestConfig(t, client, appConfig("v1", 8080))
+	<-started
+	for i := 2; i <= 4; i++ {
+		publishTestConfig(t, client, appConfig(fmt.Sprintf("v%d", i), 8080))
+	}
+
+	// 快监听器不受慢监听器影响，按顺序收到事件(可能被合并)，最后一个是v4
+	previous := "v0"
+	for previous != "v4" {
+		event := fast.next(t)
+		if event.Old.AppName != previous || event.New.AppName <= previous {
+			t.Fatalf("快监听器收到的事件%s -> %s顺序错误，上一个为%s", event.Old.AppName, event.New.AppName, previous)
+		}
+		previous = event.New.AppName
+	}
+
+	close(release)
+	if event := slow.next(t); event.Old.AppName != "v0" || event.New.AppName != "v1" {
+		t.Errorf("慢监听器的第一个事件为%s -> %s，期望v0 -> v1", event.Old.AppName, event.New.AppName)
+	}
+	event := slow.next(t)
+	if event.Old.AppName != "v1" || event.New.AppName != "v4" {
+		t.Errorf("慢监听器的第二个事件为%s -> %s，期望合并为v1 -> v4", event.Old.AppName, event.New.AppName)
+	}
+	if len(event.Diff) != 1 || event.Diff[0].Path != "appName" {
+		t.Errorf("合并后的差异为%v，期望只有appName", event.Diff)
+	}
+	slow.none(t)
+}
+
+func TestConfigManagerUnsubscribe(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("v0", 8080))
+	cm := startTestManager(t, client)
+
+	recorder := newEventRecorder()
+	subscription := cm.AddListener(recorder)
+	publishTestConfig(t, client, appConfig("v1", 8080))
+	recorder.next(t)
+
+	if !subscription.Unsubscribe() {
+		t.Fatal("第一次Unsubscribe应该返回true")
+	}
+	if subscription.Unsubscribe() || cm.RemoveListener(subscription) {
+		t.Error("重复取消订阅应该返回false")
+	}
+	publishTestConfig(t, client, appConfig("v2", 8080))
+	recorder.none(t)
+}
+
+func TestConfigManagerWithReplayAndPaths(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("v0", 8080))
+	cm := startTestManager(t, client)
+
+	replay := newEventRecorder()
+	cm.AddListener(replay, WithReplay())
+	if event := replay.next(t); event.Old != nil || event.New.AppName != "v0" {
+		t.Errorf("回放事件不正确: old=%v new=%+v", event.Old, event.New)
+	}
+	plain := newEventRecorder()
+	cm.AddListener(plain)
+	plain.none(t)
+
+	database := newEventRecorder()
+	cm.AddListener(database, WithPaths("database.*"))
+	publishTestConfig(t, client, appConfig("v1", 8080))
+	database.none(t)
+	publishTestConfig(t, client, appConfig("v1", 8080)+"  username: admin\n")
+	if event := database.next(t); len(event.Diff) != 1 || event.Diff[0].Path != "database.username" {
+		t.Errorf("按路径订阅收到的差异为%v", event.Diff)
+	}
+}
+
+func TestConfigManagerStartStopRestart(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("v0", 8080))
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	ctx := context.Background()
+
+	if err := cm.Start(ctx); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	if err := cm.Start(ctx); err != nil {
+		t.Fatalf("重复启动应该是幂等的: %v", err)
+	}
+	if !client.Listening(testDataId, testGroup) {
+		t.Fatal("启动后应该在监听配置")
+	}
+
+	if err := cm.Stop(ctx); err != nil {
+		t.Fatalf("停止失败: %v", err)
+	}
+	if client.Listening(testDataId, testGroup) {
+		t.Fatal("停止后应该已经CancelListenConfig")
+	}
+	if err := cm.Stop(ctx); err != nil {
+		t.Fatalf("重复停止应该是幂等的: %v", err)
+	}
+	publishTestConfig(t, client, appConfig("v1", 8080))
+	if name := cm.GetConfig().AppName; name != "v0" {
+		t.Errorf("停止后配置变成了%s", name)
+	}
+
+	// 再次启动时重新拉取最新配置并恢复监听
+	if err := cm.Start(ctx); err != nil {
+		t.Fatalf("重新启动失败: %v", err)
+	}
+	defer cm.Stop(ctx)
+	if name := cm.GetConfig().AppName; name != "v1" {
+		t.Errorf("重新启动后配置为%s，期望v1", name)
+	}
+	publishTestConfig(t, client, appConfig("v2", 8080))
+	if name := cm.GetConfig().AppName; name != "v2" {
+		t.Errorf("重新启动后没有收到变更，配置为%s", name)
+	}
+}
+
+func TestConfigManagerSnapshotFallback(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("v1", 8080))
+
+	// 第一次启动成功，写出本地快照
+	first := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	first.SetSnapshotFile(snapshot)
+	if err := first.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	first.Stop(context.Background())
+	if _, err := os.Stat(snapshot); err != nil {
+		t.Fatalf("没有写出本地快照: %v", err)
+	}
+
+	client.SetError(errors.New("nacos unavailable"))
+
+	// 没有快照时启动失败
+	noSnapshot := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	if err := noSnapshot.Start(context.Background()); err == nil {
+		noSnapshot.Stop(context.Background())
+		t.Fatal("Nacos不可用且没有快照时应该启动失败")
+	}
+
+	// 有快照时用快照启动，标记为过期
+	second := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	second.SetSnapshotFile(snapshot)
+	if err := second.Start(context.Background()); err != nil {
+		t.Fatalf("使用本地快照启动失败: %v", err)
+	}
+	defer second.Stop(context.Background())
+	if status := second.Status(); status.Source != SourceLocalSnapshot || !status.Stale {
+		t.Errorf("使用快照启动后的状态为%+v", status)
+	}
+	if name := second.GetConfig().AppName; name != "v1" {
+		t.Errorf("快照中的配置为%s，期望v1", name)
+	}
+
+	// Nacos恢复后收到推送，切换回实时配置
+	client.SetError(nil)
+	publishTestConfig(t, client, appConfig("v2", 8080))
+	if status := second.Status(); status.Source != SourceNacos || status.Stale {
+		t.Errorf("Nacos恢复后的状态为%+v", status)
+	}
+	if name := second.GetConfig().AppName; name != "v2" {
+		t.Errorf("Nacos恢复后配置为%s，期望v2", name)
+	}
+}
+
+func TestConfigManagerSyncListenerTimeout(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("v0", 8080))
+	cm := startTestManager(t, client)
+
+	// 同步监听器一直阻塞，超时后更新不能被后续排队的事件卡住
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	cm.AddListener(ConfigChangeListenerFunc[ConfigData](func(event *ConfigChangeEvent[ConfigData]) {
+		<-release
+	}), WithSync(), WithTimeout(100*time.Millisecond))
+
+	for _, appName := range []string{"v1", "v2"} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			publishTestConfig(t, client, appConfig(appName, 8080))
+		}()
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("同步监听器超时后发布%s被阻塞", appName)
+		}
+		if config := cm.GetConfig(); config.AppName != appName {
+			t.Fatalf("发布%s后GetConfig返回%s", appName, config.AppName)
+		}
+	}
+}
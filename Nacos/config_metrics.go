@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 配置更新指标，按Prometheus文本格式输出，不依赖Prometheus客户端库
+// ConfigMetrics本身是一个AuditSink，从审计事件中统计：
+//   nacos_config_updates_total            配置更新次数，按结果(applied/unchanged/rejected)区分
+//   nacos_config_update_failures_total    配置被拒绝的次数，按阶段区分
+//   nacos_config_update_duration_seconds  从收到配置到同步监听器处理完成的耗时
+//   nacos_config_listener_duration_seconds 监听器处理配置变更的耗时
+//   nacos_config_listener_failures_total  监听器panic或超时的次数
+// 用法: metrics := NewConfigMetrics(); cm.AddAuditSink(metrics); http.Handle("/metrics", metrics)
+
+// defaultMetricBuckets 与Prometheus客户端库一致的默认桶(秒)
+var defaultMetricBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ConfigMetrics 配置更新指标
+type ConfigMetrics struct {
+	mutex            sync.Mutex
+	updates          *counterVec
+	failures         *counterVec
+	updateDuration   *histogramVec
+	listenerDuration *histogramVec
+	listenerFailures *counterVec
+}
+
+// counterVec 带标签的计数器
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	values map[string]float64 //编码后的标签 -> 值
+}
+
+// histogramVec 带标签的直方图
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogram
+}
+
+// histogram 单个直方图，counts[i]为落在第i个桶(不含更小的桶)的次数
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewConfigMetrics 创建配置更新指标
+func NewConfigMetrics() *ConfigMetrics {
+	return &ConfigMetrics{
+		updates: &counterVec{
+			name:   "nacos_config_updates_total",
+			help:   "配置更新次数",
+			labels: []string{"namespace", "data_id", "group", "outcome"},
+			values: make(map[string]float64),
+		},
+		failures: &counterVec{
+			name:   "nacos_config_update_failures_total",
+			help:   "配置被拒绝的次数",
+			labels: []string{"namespace", "data_id", "group", "stage"},
+			values: make(map[string]float64),
+		},
+		updateDuration: &histogramVec{
+			name:    "nacos_config_update_duration_seconds",
+			help:    "从收到配置到同步监听器处理完成的耗时",
+			labels:  []string{"namespace", "data_id", "group"},
+			buckets: defaultMetricBuckets,
+			values:  make(map[string]*histogram),
+		},
+		listenerDuration: &histogramVec{
+			name:    "nacos_config_listener_duration_seconds",
+			help:    "监听器处理配置变更的耗时",
+			labels:  []string{"listener", "result"},
+			buckets: defaultMetricBuckets,
+			values:  make(map[string]*histogram),
+		},
+		listenerFailures: &counterVec{
+			name:   "nacos_config_listener_failures_total",
+			help:   "监听器panic或超时的次数",
+			labels: []string{"listener", "result"},
+			values: make(map[string]float64),
+		},
+	}
+}
+
+// WriteAudit 实现AuditSink接口，按审计事件更新指标
+func (m *ConfigMetrics) WriteAudit(event AuditEvent) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.updates.add(1, event.Namespace, event.DataId, event.Group, event.Outcome)
+	if event.Outcome == AuditRejected {
+		m.failures.add(1, event.Namespace, event.DataId, event.Group, event.Stage)
+	}
+	m.updateDuration.observe(event.DurationMs/1000, event.Namespace, event.DataId, event.Group)
+	for _, outcome := range event.Listeners {
+		switch outcome.Result {
+		case ListenerFiltered, ListenerDropped, auditPending:
+			// 没有真正执行回调或还没有结果，不计入耗时
+		case ListenerPanic, ListenerTimeout:
+			m.listenerFailures.add(1, outcome.Listener, outcome.Result)
+			m.listenerDuration.observe(outcome.DurationMs/1000, outcome.Listener, outcome.Result)
+		default:
+			m.listenerDuration.observe(outcome.DurationMs/1000, outcome.Listener, outcome.Result)
+		}
+	}
+	return nil
+}
+
+// WritePrometheus 按Prometheus文本格式输出全部指标
+func (m *ConfigMetrics) WritePrometheus(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bw := bufio.NewWriter(w)
+	m.updates.write(bw)
+	m.failures.write(bw)
+	m.updateDuration.write(bw)
+	m.listenerDuration.write(bw)
+	m.listenerFailures.write(bw)
+	return bw.Flush()
+}
+
+// ServeHTTP 实现http.Handler接口，可以直接挂到/metrics
+func (m *ConfigMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.WritePrometheus(w); err != nil {
+		logf("输出配置指标失败: %v\n", err)
+	}
+}
+
+// add 计数器增加delta
+func (c *counterVec) add(delta float64, labelValues ...string) {
+	c.values[encodeLabels(c.labels, labelValues)] += delta
+}
+
+// write 输出计数器
+func (c *counterVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, labels := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s} %s\n", c.name, labels, formatMetricValue(c.values[labels]))
+	}
+}
+
+// observe 记录一次观测值
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	labels := encodeLabels(h.labels, labelValues)
+	hist, ok := h.values[labels]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[labels] = hist
+	}
+	i := sort.SearchFloat64s(h.buckets, value)
+	hist.counts[i]++
+	hist.sum += value
+	hist.count++
+}
+
+// write 输出直方图，桶计数按Prometheus约定累加
+func (h *histogramVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, labels := range sortedKeys(h.values) {
+		hist := h.values[labels]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, labels, formatMetricValue(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, labels, hist.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, labels, formatMetricValue(hist.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, labels, hist.count)
+	}
+}
+
+// labelEscaper 转义标签值中的反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// encodeLabels 把标签编码为 a="x",b="y" 形式，同时作为map的key
+func encodeLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// formatMetricValue 按Prometheus习惯格式化数值
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigMetricsPrometheusOutput(t *testing.T) {
+	metrics := NewConfigMetrics()
+	metrics.WriteAudit(AuditEvent{
+		Outcome:    AuditApplied,
+		Namespace:  "ns",
+		DataId:     "app.yaml",
+		Group:      "test",
+		DurationMs: 250,
+		Listeners: []ListenerOutcome{
+			{Listener: "recorder", Result: ListenerOK, DurationMs: 20},
+			{Listener: "slow", Sync: true, Result: ListenerTimeout, DurationMs: 150},
+			{Listener: "db", Result: ListenerFiltered},
+			{Listener: "late", Result: auditPending},
+		},
+	})
+	metrics.WriteAudit(AuditEvent{
+		Outcome:    AuditRejected,
+		Namespace:  "ns",
+		DataId:     "app.yaml",
+		Group:      "test",
+		Stage:      "validate",
+		DurationMs: 500,
+	})
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type为%q", contentType)
+	}
+	out := recorder.Body.String()
+
+	labels := `namespace="ns",data_id="app.yaml",group="test"`
+	for _, line := range []string{
+		"# TYPE nacos_config_updates_total counter",
+		`nacos_config_updates_total{` + labels + `,outcome="applied"} 1`,
+		`nacos_config_updates_total{` + labels + `,outcome="rejected"} 1`,
+		`nacos_config_update_failures_total{` + labels + `,stage="validate"} 1`,
+		// 桶计数累加，等于上界的观测值落在该桶内
+		"# TYPE nacos_config_update_duration_seconds histogram",
+		`nacos_config_update_duration_seconds_bucket{` + labels + `,le="0.1"} 0`,
+		`nacos_config_update_duration_seconds_bucket{` + labels + `,le="0.25"} 1`,
+		`nacos_config_update_duration_seconds_bucket{` + labels + `,le="0.5"} 2`,
+		`nacos_config_update_duration_seconds_bucket{` + labels + `,le="10"} 2`,
+		`nacos_config_update_duration_seconds_bucket{` + labels + `,le="+Inf"} 2`,
+		`nacos_config_update_duration_seconds_sum{` + labels + `} 0.75`,
+		`nacos_config_update_duration_seconds_count{` + labels + `} 2`,
+		`nacos_config_listener_duration_seconds_bucket{listener="recorder",result="ok",le="0.01"} 0`,
+		`nacos_config_listener_duration_seconds_bucket{listener="recorder",result="ok",le="0.025"} 1`,
+		`nacos_config_listener_duration_seconds_bucket{listener="slow",result="timeout",le="0.1"} 0`,
+		`nacos_config_listener_duration_seconds_bucket{listener="slow",result="timeout",le="0.25"} 1`,
+		`nacos_config_listener_failures_total{listener="slow",result="timeout"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("指标中没有%s", line)
+		}
+	}
+
+	// 没有执行回调或还没有结果的监听器不计入耗时和失败次数
+	for _, listener := range []string{`listener="db"`, `listener="late"`} {
+		if strings.Contains(out, listener) {
+			t.Errorf("指标中不应出现%s", listener)
+		}
+	}
+	if strings.Contains(out, `nacos_config_listener_failures_total{listener="recorder"`) {
+		t.Error("正常处理的监听器不应计入失败次数")
+	}
+}
+
+func TestEncodeLabelsEscapes(t *testing.T) {
+	got := encodeLabels([]string{"listener"}, []string{"a\"b\\c\nd"})
+	if want := `listener="a\"b\\c\nd"`; got != want {
+		t.Errorf("转义后的标签为%s，期望%s", got, want)
+	}
+}
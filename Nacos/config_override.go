@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// 覆盖层：部署时通过环境变量或命令行参数覆盖个别字段，不需要重新发布Nacos配置
+// 每次Nacos配置更新之后都会重新应用覆盖层，覆盖层按添加顺序生效，后添加的优先
+
+const sourceDefault = "default" //字段未被任何配置来源设置，保持零值
+
+// ConfigOverride 配置覆盖来源
+type ConfigOverride interface {
+	// Lookup 查找字段路径(例如 database.url)的覆盖值，source用于排查问题，例如 env:APP_DATABASE_URL
+	Lookup(path string) (value, source string, ok bool)
+}
+
+// EnvOverride 从环境变量读取覆盖值，database.url 对应 APP_DATABASE_URL，serverPort 对应 APP_SERVER_PORT
+type EnvOverride struct {
+	Prefix string
+}
+
+// NewEnvOverride 创建环境变量覆盖层，prefix为环境变量前缀，例如 APP
+func NewEnvOverride(prefix string) *EnvOverride {
+	return &EnvOverride{Prefix: prefix}
+}
+
+// Lookup 实现ConfigOverride接口
+func (o *EnvOverride) Lookup(path string) (string, string, bool) {
+	name := EnvName(o.Prefix, path)
+	value, ok := os.LookupEnv(name)
+	return value, "env:" + name, ok
+}
+
+// EnvName 把字段路径转换为环境变量名
+func EnvName(prefix, path string) string {
+	var parts []string
+	if prefix != "" {
+		parts = append(parts, strings.ToUpper(prefix))
+	}
+	for _, seg := range splitConfigPath(path) {
+		parts = append(parts, toScreamingSnake(seg))
+	}
+	return strings.Join(parts, "_")
+}
+
+// toScreamingSnake serverPort -> SERVER_PORT
+func toScreamingSnake(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			b.WriteByte('_')
+		}
+		if r == '-' || r == '.' {
+			r = '_'
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// FlagOverride 从命令行参数读取覆盖值，只有命令行上显式传入的参数才会生效
+type FlagOverride struct {
+	flagSet *flag.FlagSet
+}
+
+// Lookup 实现ConfigOverride接口
+func (o *FlagOverride) Lookup(path string) (string, string, bool) {
+	if !o.flagSet.Parsed() {
+		return "", "", false
+	}
+	var value string
+	found := false
+	o.flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == path {
+			value = f.Value.String()
+			found = true
+		}
+	})
+	return value, "flag:-" + path, found
+}
+
+// AddOverride 添加覆盖层，后添加的覆盖层优先级更高
+func (cm *ConfigManager[T]) AddOverride(override ConfigOverride) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.overrides = append(cm.overrides, override)
+}
+
+// BindFlags 为配置结构体的每个字段注册同名命令行参数(例如 -database.url)，并作为覆盖层添加
+// 需要在flagSet.Parse之前调用；已经存在的同名参数不会重复注册
+func (cm *ConfigManager[T]) BindFlags(flagSet *flag.FlagSet) *FlagOverride {
+	var paths []string
+	collectLeafPaths(reflect.TypeOf((*T)(nil)).Elem(), "", &paths)
+	for _, path := range paths {
+		if flagSet.Lookup(path) == nil {
+			flagSet.String(path, "", fmt.Sprintf("覆盖配置项%s(环境变量写法: %s)", path, EnvName("APP", path)))
+		}
+	}
+
+	override := &FlagOverride{flagSet: flagSet}
+	cm.AddOverride(override)
+	return override
+}
+
+// FieldSource 查询字段当前值的来源，例如 nacos:DEFAULT_GROUP/app.yaml、env:APP_DATABASE_URL、flag:-serverPort
+// 列表元素(features[0])会退回到列表本身(features)的来源
+func (cm *ConfigManager[T]) FieldSource(path string) string {
+	sources := cm.FieldSources()
+	for p := path; p != ""; p = parentPath(p) {
+		if source, ok := sources[p]; ok {
+			return source
+		}
+	}
+	return sourceDefault
+}
+
+// FieldSources 返回所有字段当前值的来源
+func (cm *ConfigManager[T]) FieldSources() map[string]string {
+	sources := cm.sources.Load()
+	if sources == nil {
+		return map[string]string{}
+	}
+	out := make(map[string]string, len(*sources))
+	for k, v := range *sources {
+		out[k] = v
+	}
+	return out
+}
+
+// applyOverrides 把覆盖层的值写入配置，并在sources中记录字段来源
+func (cm *ConfigManager[T]) applyOverrides(config *T, sources map[string]string) error {
+	cm.mutex.RLock()
+	overrides := cm.overrides
+	cm.mutex.RUnlock()
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	var paths []string
+	collectLeafPaths(reflect.TypeOf(config).Elem(), "", &paths)
+	root := reflect.ValueOf(config).Elem()
+	for _, path := range paths {
+		for _, override := range overrides {
+			value, source, ok := override.Lookup(path)
+			if !ok {
+				continue
+			}
+			field, err := fieldByPath(root, path)
+			if err != nil {
+				return err
+			}
+			if err := assignScalar(field, path, value); err != nil {
+				return fmt.Errorf("%s的覆盖值无效: %v", source, err)
+			}
+			sources[path] = source
+		}
+	}
+	return nil
+}
+
+// collectLeafPaths 按类型收集可覆盖的叶子字段：标量和标量列表(列表用逗号分隔)
+func collectLeafPaths(t reflect.Type, prefix string, out *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			collectLeafPaths(f.Type, joinPath(prefix, configFieldName(f)), out)
+		}
+	case reflect.Slice, reflect.Array:
+		if isScalarKind(t.Elem().Kind()) && prefix != "" {
+			*out = append(*out, prefix)
+		}
+	case reflect.Map, reflect.Interface, reflect.Chan, reflect.Func:
+		// 动态结构无法按类型推导路径，不支持覆盖
+	default:
+		if prefix != "" {
+			*out = append(*out, prefix)
+		}
+	}
+}
+
+// isScalarKind 是否为可以从字符串转换的标量类型
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// fieldByPath 按字段路径定位结构体字段，途经的nil指针会被初始化
+func fieldByPath(v reflect.Value, path string) (reflect.Value, error) {
+	for _, seg := range splitConfigPath(path) {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("字段路径%s无法定位", path)
+		}
+		field, ok := fieldByConfigName(v, seg)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("字段路径%s不存在", path)
+		}
+		v = field
+	}
+	return v, nil
+}
+
+// parentPath database.url -> database，features[0] -> features
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndex(path, "["); i >= 0 {
+			return path[:i]
+		}
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// recordLayerSources 记录某一层配置中出现的字段，列表整体记为一个字段
+// 键值结构与之前的层深度合并；标量、列表会整体替换之前的层，因此同时清掉之前记录的子字段
+func recordLayerSources(values map[string]interface{}, prefix, source string, sources map[string]string) {
+	for key, value := range values {
+		path := joinPath(prefix, key)
+		if child, ok := value.(map[string]interface{}); ok {
+			delete(sources, path)
+			recordLayerSources(child, path, source, sources)
+			continue
+		}
+		clearChildSources(sources, path)
+		sources[path] = source
+	}
+}
+
+// clearChildSources 删除path下所有子字段(path.x、path[0])的来源
+func clearChildSources(sources map[string]string, path string) {
+	for p := range sources {
+		if strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[") {
+			delete(sources, p)
+		}
+	}
+}
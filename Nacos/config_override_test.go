@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		prefix string
+		path   string
+		want   string
+	}{
+		{"APP", "serverPort", "APP_SERVER_PORT"},
+		{"app", "database.url", "APP_DATABASE_URL"},
+		{"APP", "database.maxOpenConns", "APP_DATABASE_MAX_OPEN_CONNS"},
+		{"", "oauth2Token", "OAUTH2_TOKEN"},
+		{"APP", "HTTPServer", "APP_HTTPSERVER"},
+		{"APP", "max-conns", "APP_MAX_CONNS"},
+		{"APP", "features", "APP_FEATURES"},
+	}
+	for _, tt := range tests {
+		if got := EnvName(tt.prefix, tt.path); got != tt.want {
+			t.Errorf("EnvName(%q, %q) = %q，期望%q", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestConfigManagerFlagOverridesEnv(t *testing.T) {
+	t.Setenv("APP_SERVER_PORT", "9000")
+	t.Setenv("APP_DATABASE_URL", "mysql://env/app")
+	t.Setenv("APP_FEATURES", "a,b")
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, appConfig("demo", 8080))
+
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	cm.AddOverride(NewEnvOverride("APP"))
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	cm.BindFlags(flagSet)
+	if err := flagSet.Parse([]string{"-serverPort=9100"}); err != nil {
+		t.Fatalf("解析命令行参数失败: %v", err)
+	}
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	defer cm.Stop(context.Background())
+
+	// 后添加的命令行参数优先于环境变量
+	config := cm.GetConfig()
+	if config.ServerPort != 9100 || config.Database.Url != "mysql://env/app" || strings.Join(config.Features, ",") != "a,b" {
+		t.Fatalf("覆盖后的配置为%+v", config)
+	}
+
+	want := map[string]string{
+		"appName":           "nacos:test/app.yaml",
+		"serverPort":        "flag:-serverPort",
+		"database.url":      "env:APP_DATABASE_URL",
+		"database.username": sourceDefault,
+		"features":          "env:APP_FEATURES",
+		"features[1]":       "env:APP_FEATURES",
+	}
+	for path, source := range want {
+		if got := cm.FieldSource(path); got != source {
+			t.Errorf("%s的来源为%q，期望%q", path, got, source)
+		}
+	}
+
+	// 覆盖值无法转换时拒绝这份配置
+	t.Setenv("APP_SERVER_PORT", "abc")
+	invalid := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	invalid.AddOverride(NewEnvOverride("APP"))
+	if err := invalid.Start(context.Background()); err == nil {
+		invalid.Stop(context.Background())
+		t.Error("覆盖值无效时应该启动失败")
+	}
+}
+
+func TestLayeredConfigFieldSources(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishFake(t, client, map[[2]string]string{
+		{testGroup, "base.yaml"}: "appName: base\nserverPort: 8080\ndatabase:\n  url: mysql://base/app\n  username: admin\n" +
+			"features: [a, b]\nflags:\n  beta:\n    enabled: true\n",
+		{testGroup, "prod.yaml"}: "appName: prod\ndatabase:\n  url: mysql://prod/app\nfeatures: [c]\nflags: null\n",
+	})
+	cm := NewLayeredConfigManager[ConfigData](client,
+		vo.ConfigParam{DataId: "base.yaml", Group: testGroup, Type: vo.YAML},
+		vo.ConfigParam{DataId: "prod.yaml", Group: testGroup, Type: vo.YAML},
+	)
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	defer cm.Stop(context.Background())
+
+	base, prod := "nacos:test/base.yaml", "nacos:test/prod.yaml"
+	want := map[string]string{
+		"appName":           prod,
+		"serverPort":        base,
+		"database.url":      prod,
+		"database.username": base,
+		"features":          prod,
+		"features[0]":       prod,
+		"flags":             prod,
+		"flags.beta":        prod,
+	}
+	for path, source := range want {
+		if got := cm.FieldSource(path); got != source {
+			t.Errorf("%s的来源为%q，期望%q", path, got, source)
+		}
+	}
+
+	// 被后面的层整体替换的字段，不再保留之前的层记录的子字段来源
+	for path := range cm.FieldSources() {
+		if strings.HasPrefix(path, "flags.") {
+			t.Errorf("flags被替换后仍然记录了%s的来源", path)
+		}
+	}
+	if config := cm.GetConfig(); len(config.Flags) != 0 || strings.Join(config.Features, ",") != "c" {
+		t.Errorf("合并后的配置为%+v", config)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// 安全发布配置：发布前按目标结构体校验、展示与线上内容的差异，并基于MD5做比较后交换，
+// 避免两个人同时修改同一份配置时后发布的人悄悄覆盖前一个人的修改
+// nacos-sdk-go v1.1.4 的PublishConfig不支持casMd5参数，因此比较在客户端完成：
+// 发布前重新读取一次线上内容并比较MD5，仍存在极短的竞争窗口
+
+// ErrConfigConflict 线上配置已被其他人修改
+var ErrConfigConflict = errors.New("线上配置已被修改")
+
+// ConfigSchema 发布前校验配置内容的目标结构体
+type ConfigSchema interface {
+	// Check 解码并校验新内容，返回与旧内容之间的字段级差异，oldContent为空表示首次发布
+	Check(configType, oldContent, newContent string) (ConfigDiff, error)
+}
+
+// configSchema 以Go结构体T作为配置的结构定义
+type configSchema[T any] struct{}
+
+// SchemaOf 使用配置结构体T作为发布校验的目标，validate标签和Validate方法都会生效
+func SchemaOf[T any]() ConfigSchema {
+	return configSchema[T]{}
+}
+
+// Check 实现ConfigSchema接口
+func (configSchema[T]) Check(configType, oldContent, newContent string) (ConfigDiff, error) {
+	decoder, err := LookupDecoder(configType)
+	if err != nil {
+		return nil, err
+	}
+	newConfig := new(T)
+	if err := decoder.Decode([]byte(newContent), newConfig); err != nil {
+		return nil, fmt.Errorf("%s解析失败: %v", normalizeConfigType(configType), err)
+	}
+	if err := validateConfig(newConfig, nil); err != nil {
+		return nil, err
+	}
+
+	// 线上的旧内容解析失败时不影响发布，按首次发布展示差异
+	var oldConfig *T
+	if strings.TrimSpace(oldContent) != "" {
+		oldConfig = new(T)
+		if decoder.Decode([]byte(oldContent), oldConfig) != nil {
+			oldConfig = nil
+		}
+	}
+	return maskSecretChanges(DiffConfig(oldConfig, newConfig), reflect.TypeOf(newConfig).Elem()), nil
+}
+
+// PublishRequest 一次发布请求
+type PublishRequest struct {
+	DataId      string
+	Group       string
+	Type        string       //配置类型，为空时按yaml处理
+	Content     string       //要发布的内容
+	Schema      ConfigSchema //目标结构体，为空表示不校验
+	ExpectedMD5 string       //期望的线上内容MD5，为空表示以本次读取到的内容为准
+	DryRun      bool         //只校验和展示差异，不发布
+}
+
+// PublishResult 发布结果
+type PublishResult struct {
+	OldMD5    string     //发布前的线上内容MD5，配置不存在时为空
+	NewMD5    string     //新内容的MD5
+	Diff      ConfigDiff //字段级差异，没有Schema时为空
+	Unchanged bool       //新内容与线上内容完全一致
+	Published bool       //是否已经发布
+}
+
+// PublishChecked 校验并发布配置，线上内容的MD5与期望不一致时返回ErrConfigConflict
+func PublishChecked(client config_client.IConfigClient, req PublishRequest) (*PublishResult, error) {
+	if req.DataId == "" || req.Group == "" {
+		return nil, fmt.Errorf("dataId和group不能为空")
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, fmt.Errorf("配置内容不能为空")
+	}
+	param := vo.ConfigParam{DataId: req.DataId, Group: req.Group, Type: vo.ConfigType(normalizeConfigType(req.Type))}
+
+	current, err := client.GetConfig(param)
+	if err != nil {
+		return nil, fmt.Errorf("读取线上配置[%s/%s]失败: %v", req.Group, req.DataId, err)
+	}
+	result := &PublishResult{OldMD5: onlineMD5(current), NewMD5: contentMD5(req.Content)}
+	if req.ExpectedMD5 != "" && req.ExpectedMD5 != result.OldMD5 {
+		return result, fmt.Errorf("%w: 期望MD5为%s，实际为%s，请重新确认差异后再发布", ErrConfigConflict, req.ExpectedMD5, displayMD5(result.OldMD5))
+	}
+
+	if req.Schema != nil {
+		result.Diff, err = req.Schema.Check(string(param.Type), current, req.Content)
+		if err != nil {
+			return result, fmt.Errorf("配置内容校验失败: %v", err)
+		}
+	}
+	if result.NewMD5 == result.OldMD5 {
+		result.Unchanged = true
+		return result, nil
+	}
+	if req.DryRun {
+		return result, nil
+	}
+
+	// 比较后交换：展示差异之后可能已经有人发布过，发布前再确认一次线上内容
+	latest, err := client.GetConfig(param)
+	if err != nil {
+		return result, fmt.Errorf("读取线上配置[%s/%s]失败: %v", req.Group, req.DataId, err)
+	}
+	if onlineMD5(latest) != result.OldMD5 {
+		return result, fmt.Errorf("%w: 读取之后MD5由%s变为%s，请重新确认差异后再发布", ErrConfigConflict, displayMD5(result.OldMD5), displayMD5(onlineMD5(latest)))
+	}
+
+	param.Content = req.Content
+	ok, err := client.PublishConfig(param)
+	if err != nil {
+		return result, fmt.Errorf("发布配置[%s/%s]失败: %v", req.Group, req.DataId, err)
+	}
+	if !ok {
+		return result, fmt.Errorf("发布配置[%s/%s]失败", req.Group, req.DataId)
+	}
+	result.Published = true
+	return result, nil
+}
+
+// ConfigTypeOfFile 按文件扩展名推断配置类型，无法识别时按text处理
+func ConfigTypeOfFile(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if _, err := LookupDecoder(ext); err != nil {
+		return "text"
+	}
+	return normalizeConfigType(ext)
+}
+
+// onlineMD5 线上内容的MD5，配置不存在(内容为空)时为空字符串
+func onlineMD5(content string) string {
+	if content == "" {
+		return ""
+	}
+	return contentMD5(content)
+}
+
+// displayMD5 用于提示信息的MD5，配置不存在时显示为"(不存在)"
+func displayMD5(md5sum string) string {
+	if md5sum == "" {
+		return "(不存在)"
+	}
+	return md5sum
+}
+
+// maskSecretChanges 隐藏敏感字段的明文，占位符和ENC(...)加密值原样展示
+func maskSecretChanges(diff ConfigDiff, t reflect.Type) ConfigDiff {
+	var secrets []string
+	collectSecretPaths(t, "", &secrets)
+	if len(secrets) == 0 {
+		return diff
+	}
+
+	mask := func(value interface{}) interface{} {
+		s, ok := value.(string)
+		if !ok || s == "" || secretPlaceholder.MatchString(s) || encryptedValue.MatchString(strings.TrimSpace(s)) {
+			return value
+		}
+		return redactedValue
+	}
+	out := make(ConfigDiff, len(diff))
+	for i, change := range diff {
+		if matchAnyPath(secrets, change.Path) {
+			change.Old = mask(change.Old)
+			change.New = mask(change.New)
+		}
+		out[i] = change
+	}
+	return out
+}
+
+// collectSecretPaths 按类型收集带secret:"true"标签的字段路径
+func collectSecretPaths(t reflect.Type, prefix string, out *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		path := joinPath(prefix, configFieldName(f))
+		if f.Tag.Get("secret") == "true" {
+			*out = append(*out, path)
+			continue
+		}
+		collectSecretPaths(f.Type, path, out)
+	}
+}
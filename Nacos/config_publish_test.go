@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// racingClient 第一次读取线上配置之后，模拟另一个人抢先发布了新内容
+type racingClient struct {
+	*FakeConfigClient
+	reads   int
+	content string
+}
+
+// GetConfig 覆盖FakeConfigClient.GetConfig
+func (c *racingClient) GetConfig(param vo.ConfigParam) (string, error) {
+	content, err := c.FakeConfigClient.GetConfig(param)
+	c.reads++
+	if c.reads == 1 {
+		c.FakeConfigClient.PublishConfig(vo.ConfigParam{DataId: param.DataId, Group: param.Group, Content: c.content})
+	}
+	return content, err
+}
+
+// onlineContent 读取FakeConfigClient中的线上内容
+func onlineContent(t *testing.T, client *FakeConfigClient) string {
+	t.Helper()
+	content, err := client.GetConfig(vo.ConfigParam{DataId: testDataId, Group: testGroup})
+	if err != nil {
+		t.Fatalf("读取线上配置失败: %v", err)
+	}
+	return content
+}
+
+func TestPublishCheckedConflict(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1, v2 := appConfig("v1", 8080), appConfig("v2", 8080)
+	publishTestConfig(t, client, v1)
+
+	// 期望的MD5与线上不一致
+	result, err := PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: v2, ExpectedMD5: contentMD5("other")})
+	if !errors.Is(err, ErrConfigConflict) || result.Published {
+		t.Fatalf("期望MD5不一致时返回(%+v, %v)", result, err)
+	}
+	if content := onlineContent(t, client); content != v1 {
+		t.Fatalf("冲突时不应发布，线上内容为%q", content)
+	}
+
+	// 展示差异之后、发布之前被其他人修改
+	other := appConfig("other", 8080)
+	racing := &racingClient{FakeConfigClient: client, content: other}
+	result, err = PublishChecked(racing, PublishRequest{DataId: testDataId, Group: testGroup, Content: v2, ExpectedMD5: contentMD5(v1)})
+	if !errors.Is(err, ErrConfigConflict) || result.Published {
+		t.Fatalf("发布前线上内容被修改时返回(%+v, %v)", result, err)
+	}
+	if content := onlineContent(t, client); content != other {
+		t.Errorf("冲突时不应覆盖其他人的修改，线上内容为%q", content)
+	}
+
+	// 以最新的MD5重新发布成功
+	result, err = PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: v2, ExpectedMD5: contentMD5(other)})
+	if err != nil || !result.Published || result.OldMD5 != contentMD5(other) || result.NewMD5 != contentMD5(v2) {
+		t.Fatalf("重新发布返回(%+v, %v)", result, err)
+	}
+	if content := onlineContent(t, client); content != v2 {
+		t.Errorf("发布后线上内容为%q", content)
+	}
+}
+
+func TestPublishCheckedDryRunAndUnchanged(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1, v2 := appConfig("v1", 8080), appConfig("v1", 9090)
+	schema := SchemaOf[ConfigData]()
+
+	// 首次发布：没有旧内容，所有字段都是新增
+	result, err := PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: v1, Schema: schema, DryRun: true})
+	if err != nil || result.Published || result.OldMD5 != "" || len(result.Diff) == 0 {
+		t.Fatalf("首次发布dry-run返回(%+v, %v)", result, err)
+	}
+	for _, change := range result.Diff {
+		if change.Kind != ChangeAdded {
+			t.Errorf("首次发布时%s的变更类型为%s", change.Path, change.Kind)
+		}
+	}
+	if content := onlineContent(t, client); content != "" {
+		t.Fatalf("dry-run不应发布，线上内容为%q", content)
+	}
+
+	publishTestConfig(t, client, v1)
+	result, err = PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: v2, Schema: schema, DryRun: true})
+	if err != nil || result.Published || len(result.Diff) != 1 || result.Diff[0].Path != "serverPort" {
+		t.Fatalf("dry-run返回(%+v, %v)，期望只有serverPort的差异", result, err)
+	}
+	if content := onlineContent(t, client); content != v1 {
+		t.Errorf("dry-run不应发布，线上内容为%q", content)
+	}
+
+	// 内容与线上完全一致时不发布
+	result, err = PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: v1, Schema: schema})
+	if err != nil || !result.Unchanged || result.Published || len(result.Diff) != 0 {
+		t.Errorf("内容未变化时返回(%+v, %v)", result, err)
+	}
+}
+
+func TestPublishCheckedSchemaRejection(t *testing.T) {
+	client := NewFakeConfigClient("")
+	v1 := appConfig("v1", 8080)
+	publishTestConfig(t, client, v1)
+	schema := SchemaOf[ConfigData]()
+
+	for name, content := range map[string]string{
+		"语法错误":   "appName: [v2\n",
+		"缺少必填字段": "appName: v2\nserverPort: 8080\n",
+		"端口越界":   "appName: v2\nserverPort: 70000\ndatabase:\n  url: mysql://localhost/app\n",
+	} {
+		result, err := PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: content, Schema: schema})
+		if err == nil || result.Published {
+			t.Errorf("%s的内容应该被拒绝，实际返回(%+v, %v)", name, result, err)
+		}
+	}
+	if content := onlineContent(t, client); content != v1 {
+		t.Errorf("校验失败时不应发布，线上内容为%q", content)
+	}
+
+	if _, err := PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: "  \n"}); err == nil {
+		t.Error("空内容应该被拒绝")
+	}
+}
+
+func TestPublishCheckedMasksSecrets(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, secretConfig("old-plain-pass"))
+	schema := SchemaOf[ConfigData]()
+
+	tests := []struct {
+		password string
+		wantOld  interface{}
+		wantNew  interface{}
+	}{
+		{"new-plain-pass", redactedValue, redactedValue},
+		{"${secret:db/password}", redactedValue, "${secret:db/password}"},
+		{"ENC(YWJjZA==)", redactedValue, "ENC(YWJjZA==)"},
+	}
+	for _, tt := range tests {
+		result, err := PublishChecked(client, PublishRequest{DataId: testDataId, Group: testGroup, Content: secretConfig(tt.password), Schema: schema, DryRun: true})
+		if err != nil {
+			t.Fatalf("dry-run失败: %v", err)
+		}
+		if len(result.Diff) != 1 || result.Diff[0].Path != "database.password" {
+			t.Fatalf("改为%s时的差异为%v", tt.password, result.Diff)
+		}
+		if change := result.Diff[0]; change.Old != tt.wantOld || change.New != tt.wantNew {
+			t.Errorf("改为%s时差异展示为%v -> %v，期望%v -> %v", tt.password, change.Old, change.New, tt.wantOld, tt.wantNew)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 敏感配置：密码等字段不以明文存放在Nacos上
+//   ${secret:db/password}  占位符，通过SecretProvider从文件、环境变量等位置读取
+//   ENC(base64密文)         AES-GCM加密的值，用本地密钥解密
+// 解析出的明文和带secret:"true"标签的字段值会登记到脱敏表，所有日志输出前自动替换为******
+
+const redactedValue = "******"
+
+// minRedactLength 短于该长度的值不登记脱敏，避免把日志中所有的单个字符都替换掉
+const minRedactLength = 4
+
+// ErrSecretNotFound 密钥提供者中没有该密钥，继续尝试下一个提供者
+var ErrSecretNotFound = errors.New("密钥不存在")
+
+var (
+	secretPlaceholder = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+	encryptedValue    = regexp.MustCompile(`^ENC\(([^)]*)\)$`)
+	// secretLine 配置原文中看起来是敏感信息的行，例如 password: xxx、db.token=xxx
+	secretLine = regexp.MustCompile(`(?im)^(\s*[\w.\-]*(?:password|passwd|secret|token|credential)[\w.\-]*\s*[:=]\s*)(\S.*)$`)
+)
+
+// SecretProvider 密钥提供者
+type SecretProvider interface {
+	// GetSecret 按名称读取密钥，例如 db/password；不存在时返回ErrSecretNotFound
+	GetSecret(name string) (string, error)
+}
+
+// SecretProviderFunc 函数形式的密钥提供者
+type SecretProviderFunc func(name string) (string, error)
+
+// GetSecret 实现SecretProvider接口
+func (f SecretProviderFunc) GetSecret(name string) (string, error) {
+	return f(name)
+}
+
+// FileSecretProvider 从目录中读取密钥，db/password 对应 Dir/db/password 文件，
+// 适用于Kubernetes Secret挂载的目录；文件末尾的换行会被去掉
+type FileSecretProvider struct {
+	Dir string
+}
+
+// NewFileSecretProvider 创建文件密钥提供者
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	return &FileSecretProvider{Dir: dir}
+}
+
+// GetSecret 实现SecretProvider接口
+func (p *FileSecretProvider) GetSecret(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("密钥名称%q不能跳出目录%s", name, p.Dir)
+	}
+	data, err := os.ReadFile(filepath.Join(p.Dir, clean))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretProvider 从环境变量读取密钥，前缀为SECRET时 db/password 对应 SECRET_DB_PASSWORD
+type EnvSecretProvider struct {
+	Prefix string
+}
+
+// NewEnvSecretProvider 创建环境变量密钥提供者
+func NewEnvSecretProvider(prefix string) *EnvSecretProvider {
+	return &EnvSecretProvider{Prefix: prefix}
+}
+
+// GetSecret 实现SecretProvider接口
+func (p *EnvSecretProvider) GetSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(p.EnvName(name))
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// EnvName 密钥名称对应的环境变量名
+func (p *EnvSecretProvider) EnvName(name string) string {
+	return EnvName(p.Prefix, strings.ReplaceAll(name, "/", "."))
+}
+
+// AddSecretProvider 添加密钥提供者，解析占位符时按添加顺序查找
+func (cm *ConfigManager[T]) AddSecretProvider(provider SecretProvider) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.secretProviders = append(cm.secretProviders, provider)
+}
+
+// SetEncryptionKey 设置解密ENC(...)的AES密钥，长度必须是16、24或32字节
+func (cm *ConfigManager[T]) SetEncryptionKey(key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("加密密钥无效: %v", err)
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.encryptionKey = append([]byte(nil), key...)
+	return nil
+}
+
+// LoadEncryptionKey 从文件读取AES密钥，文件内容可以是base64、十六进制或原始字节
+func LoadEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取加密密钥失败: %v", err)
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && validKeyLength(len(key)) {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(text); err == nil && validKeyLength(len(key)) {
+		return key, nil
+	}
+	if validKeyLength(len(data)) {
+		return data, nil
+	}
+	return nil, fmt.Errorf("加密密钥文件%s的长度无效，需要16、24或32字节", path)
+}
+
+// validKeyLength 是否为AES支持的密钥长度
+func validKeyLength(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// EncryptSecret 用AES-GCM加密明文，返回可以直接写进配置的ENC(...)值
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return "ENC(" + base64.StdEncoding.EncodeToString(sealed) + ")", nil
+}
+
+// decryptSecret 解密ENC(...)中的base64密文，密文格式为 nonce+ciphertext
+func decryptSecret(key []byte, encoded string) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("配置中有ENC(...)加密值，但没有设置解密密钥")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("密文不是有效的base64: %v", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("密文长度不足")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败，请检查密钥是否正确: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// newGCM 创建AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("加密密钥无效: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// resolveSecrets 解析配置中所有字符串字段的占位符和加密值，返回需要脱敏的明文；
+// 明文在返回前就登记到该管理器的脱敏表，配置被拒绝时错误信息中也不会出现明文
+func (cm *ConfigManager[T]) resolveSecrets(config *T) ([]string, error) {
+	cm.mutex.RLock()
+	providers := cm.secretProviders
+	key := cm.encryptionKey
+	cm.mutex.RUnlock()
+
+	var secrets []string
+	resolve := func(path, value string, secret bool) (string, error) {
+		if m := encryptedValue.FindStringSubmatch(strings.TrimSpace(value)); m != nil {
+			plaintext, err := decryptSecret(key, m[1])
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", path, err)
+			}
+			secrets = append(secrets, plaintext)
+			return plaintext, nil
+		}
+
+		var firstErr error
+		resolved := secretPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+			name := strings.TrimSpace(secretPlaceholder.FindStringSubmatch(placeholder)[1])
+			plaintext, err := lookupSecret(providers, name)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: 解析%s失败: %w", path, placeholder, err)
+				}
+				return placeholder
+			}
+			secrets = append(secrets, plaintext)
+			return plaintext
+		})
+		if secret && firstErr == nil {
+			secrets = append(secrets, resolved)
+		}
+		return resolved, firstErr
+	}
+	err := walkSecretFields(reflect.ValueOf(config).Elem(), "", false, resolve)
+	addSecretValues(cm, secrets)
+	return secrets, err
+}
+
+// lookupSecret 按顺序在提供者中查找密钥
+func lookupSecret(providers []SecretProvider, name string) (string, error) {
+	if len(providers) == 0 {
+		return "", errors.New("没有设置密钥提供者")
+	}
+	for _, provider := range providers {
+		secret, err := provider.GetSecret(name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		return secret, err
+	}
+	return "", ErrSecretNotFound
+}
+
+// walkSecretFields 遍历所有字符串字段，依次解析；secret表示字段带有secret:"true"标签，解析后的值整体需要脱敏
+func walkSecretFields(v reflect.Value, path string, secret bool, resolve func(path, value string, secret bool) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			// 接口中的值不可寻址，只处理字符串
+			if s, ok := v.Elem().Interface().(string); ok {
+				resolved, err := resolve(path, s, secret)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.ValueOf(resolved))
+				return nil
+			}
+		}
+		return walkSecretFields(v.Elem(), path, secret, resolve)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fieldSecret := secret || f.Tag.Get("secret") == "true"
+			if err := walkSecretFields(v.Field(i), joinPath(path, configFieldName(f)), fieldSecret, resolve); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkSecretFields(v.Index(i), fmt.Sprintf("%s[%d]", path, i), secret, resolve); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// map的值不可寻址，复制出来处理后再写回
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := walkSecretFields(elem, joinPath(path, fmt.Sprint(iter.Key().Interface())), secret, resolve); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.String:
+		resolved, err := resolve(path, v.String(), secret)
+		if err != nil {
+			return err
+		}
+		v.SetString(resolved)
+	}
+	return nil
+}
+
+// 脱敏表：登记过的明文在日志中统一替换为******
+// RegisterSecretValue登记的值一直有效；配置管理器解析出的明文按管理器分别保存，
+// 每次配置生效后替换为新配置中的明文，轮换掉的旧密钥不会一直留在表中
+var (
+	redactMutex  sync.RWMutex
+	redactFixed  = make(map[string]bool)                 //RegisterSecretValue登记的明文
+	redactOwners = make(map[interface{}]map[string]bool) //配置管理器 -> 当前配置中的明文
+	redactValues []string                                //以上两者的并集，按长度从长到短排列，避免短值先替换破坏长值
+)
+
+// RegisterSecretValue 登记需要在日志中脱敏的明文
+func RegisterSecretValue(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+
+	if redactFixed[value] {
+		return
+	}
+	redactFixed[value] = true
+	rebuildRedactValues()
+}
+
+// addSecretValues 为owner追加登记明文，新配置尚未生效(或被拒绝)时旧配置的明文仍然需要脱敏
+func addSecretValues(owner interface{}, values []string) {
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+
+	set := redactOwners[owner]
+	if set == nil {
+		set = make(map[string]bool)
+		redactOwners[owner] = set
+	}
+	changed := false
+	for _, value := range values {
+		if len(value) >= minRedactLength && !set[value] {
+			set[value] = true
+			changed = true
+		}
+	}
+	if changed {
+		rebuildRedactValues()
+	}
+}
+
+// replaceSecretValues 配置生效后，owner登记的明文替换为新配置中的明文
+func replaceSecretValues(owner interface{}, values []string) {
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		if len(value) >= minRedactLength {
+			set[value] = true
+		}
+	}
+	redactOwners[owner] = set
+	rebuildRedactValues()
+}
+
+// removeSecretValues 注销owner登记的全部明文，配置管理器停止时调用
+func removeSecretValues(owner interface{}) {
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+
+	if _, ok := redactOwners[owner]; !ok {
+		return
+	}
+	delete(redactOwners, owner)
+	rebuildRedactValues()
+}
+
+// rebuildRedactValues 重新计算脱敏表，调用方需持有redactMutex
+func rebuildRedactValues() {
+	all := make(map[string]bool, len(redactFixed))
+	for value := range redactFixed {
+		all[value] = true
+	}
+	for _, set := range redactOwners {
+		for value := range set {
+			all[value] = true
+		}
+	}
+	values := make([]string, 0, len(all))
+	for value := range all {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	redactValues = values
+}
+
+// RedactSecrets 把文本中登记过的明文替换为******
+func RedactSecrets(text string) string {
+	redactMutex.RLock()
+	defer redactMutex.RUnlock()
+
+	for _, v := range redactValues {
+		text = strings.ReplaceAll(text, v, redactedValue)
+	}
+	return text
+}
+
+// RedactContent 脱敏配置原文：在RedactSecrets的基础上，把password、token等键的值也替换掉，
+// 用于打印尚未解析的原始配置
+func RedactContent(content string) string {
+	return secretLine.ReplaceAllString(RedactSecrets(content), "${1}"+redactedValue)
+}
+
+// logf 输出日志，登记过的明文会被替换为******
+func logf(format string, args ...interface{}) {
+	fmt.Print(RedactSecrets(fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// secretConfig 生成数据库密码为password的yaml配置
+func secretConfig(password string) string {
+	return fmt.Sprintf("appName: demo\nserverPort: 8080\ndatabase:\n  url: mysql://localhost/app\n  password: %s\n", password)
+}
+
+// testEncryptionKey 32字节的AES测试密钥，不是有效的base64或十六进制，可以按原始字节读取
+var testEncryptionKey = []byte("raw-key-0123456789abcdef-!@#$%^&")
+
+// captureStdout 捕获fn执行期间logf输出的内容
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("创建管道失败: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	fn()
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestConfigManagerResolvesSecretPlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "db"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db", "password"), []byte("from-file-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_DB_PASSWORD", "from-env-pass")
+	t.Setenv("SECRET_DB_USER", "from-env-user")
+
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, secretConfig("${secret:db/password}")+"  username: ${secret:db/user}\n")
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	cm.AddSecretProvider(NewFileSecretProvider(dir))
+	cm.AddSecretProvider(NewEnvSecretProvider("SECRET"))
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	defer cm.Stop(context.Background())
+
+	// 按添加顺序查找：文件中有的用文件，没有的退回到环境变量
+	database := cm.GetConfig().Database
+	if database.Password != "from-file-pass" || database.Username != "from-env-user" {
+		t.Fatalf("解析后的数据库配置为%+v", database)
+	}
+
+	// 任何提供者中都没有的密钥，整份配置被拒绝
+	rejected := make(chan error, 1)
+	cm.AddErrorListener(ConfigErrorListenerFunc(func(err error) { rejected <- err }))
+	publishTestConfig(t, client, secretConfig("${secret:db/missing}"))
+	err := <-rejected
+	var rejectedErr *ConfigRejectedError
+	if !errors.As(err, &rejectedErr) || rejectedErr.Stage != "secret" || !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("缺少密钥时上报的错误为%v", err)
+	}
+	if password := cm.GetConfig().Database.Password; password != "from-file-pass" {
+		t.Errorf("被拒绝后密码变成了%q", password)
+	}
+
+	if _, err := NewFileSecretProvider(dir).GetSecret("../db/password"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("跳出目录的密钥名称应该报错，实际为%v", err)
+	}
+}
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	encrypted, err := EncryptSecret(testEncryptionKey, "enc-pass-1")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	m := encryptedValue.FindStringSubmatch(encrypted)
+	if m == nil {
+		t.Fatalf("加密结果%q不是ENC(...)格式", encrypted)
+	}
+	if plaintext, err := decryptSecret(testEncryptionKey, m[1]); err != nil || plaintext != "enc-pass-1" {
+		t.Fatalf("解密得到(%q, %v)", plaintext, err)
+	}
+	if again, _ := EncryptSecret(testEncryptionKey, "enc-pass-1"); again == encrypted {
+		t.Error("两次加密应该使用不同的nonce")
+	}
+	if _, err := decryptSecret([]byte("fedcba9876543210fedcba9876543210"), m[1]); err == nil {
+		t.Error("使用错误的密钥解密应该失败")
+	}
+	if _, err := decryptSecret(nil, m[1]); err == nil {
+		t.Error("没有设置密钥时解密应该失败")
+	}
+
+	// 密钥文件支持base64、十六进制和原始字节
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"base64": base64.StdEncoding.EncodeToString(testEncryptionKey) + "\n",
+		"hex":    hex.EncodeToString(testEncryptionKey),
+		"raw":    string(testEncryptionKey),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if key, err := LoadEncryptionKey(path); err != nil || string(key) != string(testEncryptionKey) {
+			t.Errorf("读取%s格式的密钥得到(%q, %v)", name, key, err)
+		}
+	}
+
+	// 配置管理器用设置的密钥解密ENC(...)
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, secretConfig(encrypted))
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	if err := cm.SetEncryptionKey([]byte("short")); err == nil {
+		t.Error("长度无效的密钥应该被拒绝")
+	}
+	if err := cm.Start(context.Background()); err == nil {
+		cm.Stop(context.Background())
+		t.Fatal("没有设置密钥时，包含ENC(...)的配置应该被拒绝")
+	}
+	if err := cm.SetEncryptionKey(testEncryptionKey); err != nil {
+		t.Fatalf("设置密钥失败: %v", err)
+	}
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	defer cm.Stop(context.Background())
+	if password := cm.GetConfig().Database.Password; password != "enc-pass-1" {
+		t.Errorf("解密后的密码为%q", password)
+	}
+}
+
+func TestConfigManagerRedactsSecrets(t *testing.T) {
+	t.Setenv("SECRET_DB_PASSWORD", "redact-pass-1")
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, secretConfig("${secret:db/password}"))
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	cm.AddSecretProvider(NewEnvSecretProvider("SECRET"))
+	if err := cm.Start(context.Background()); err != nil {
+		t.Fatalf("启动配置管理器失败: %v", err)
+	}
+	defer cm.Stop(context.Background())
+
+	out := captureStdout(t, func() { logf("连接数据库，密码为%s\n", cm.GetConfig().Database.Password) })
+	if out != "连接数据库，密码为******\n" {
+		t.Errorf("日志输出为%q，期望密码被脱敏", out)
+	}
+	if got := RedactContent("url: x\npassword: plain-text\ndb.token = abc\n"); got != "url: x\npassword: ******\ndb.token = ******\n" {
+		t.Errorf("配置原文脱敏后为%q", got)
+	}
+
+	// 密钥轮换后新密码脱敏，旧密码从脱敏表中移除；原文加一个空格，让Nacos推送这次变更
+	t.Setenv("SECRET_DB_PASSWORD", "redact-pass-2")
+	publishTestConfig(t, client, secretConfig("${secret:db/password} "))
+	if got := RedactSecrets("redact-pass-1 redact-pass-2"); got != "redact-pass-1 ******" {
+		t.Errorf("轮换后的脱敏结果为%q", got)
+	}
+
+	// 停止后注销本管理器登记的明文
+	cm.Stop(context.Background())
+	if got := RedactSecrets("redact-pass-2"); got != "redact-pass-2" {
+		t.Errorf("停止后仍然脱敏了%q", got)
+	}
+	redactMutex.RLock()
+	_, ok := redactOwners[cm]
+	redactMutex.RUnlock()
+	if ok {
+		t.Error("停止后脱敏表中仍然保留了该管理器")
+	}
+}
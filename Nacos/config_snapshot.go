@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 本地快照：把最后一份有效配置落盘，Nacos不可用时用它启动
+
+const (
+	SourceNacos         = "nacos"          //配置来自Nacos
+	SourceLocalSnapshot = "local-snapshot" //配置来自本地快照文件
+)
+
+const (
+	snapshotRetryInitial = time.Second      //后台重连Nacos的初始间隔
+	snapshotRetryMax     = 30 * time.Second //后台重连Nacos的最大间隔
+)
+
+// ConfigStatus 当前生效配置的元信息
+type ConfigStatus struct {
+	Source   string    //配置来源，SourceNacos 或 SourceLocalSnapshot
+	Stale    bool      //是否为过期配置(来自本地快照，尚未与Nacos同步)
+	MD5      string    //配置原文的MD5，与Nacos控制台显示的一致
+	Version  int       //本地历史中的版本号，见ConfigManager.ListVersions
+	LoadedAt time.Time //生效时间
+}
+
+// configSnapshotFile 快照文件的内容
+type configSnapshotFile struct {
+	Layers  []snapshotLayer `json:"layers"`
+	MD5     string          `json:"md5"`
+	SavedAt time.Time       `json:"savedAt"`
+}
+
+// snapshotLayer 快照中一层配置的原文
+type snapshotLayer struct {
+	DataId  string `json:"dataId"`
+	Group   string `json:"group"`
+	Type    string `json:"type"`
+	MD5     string `json:"md5"`
+	Content string `json:"content"`
+}
+
+// contentMD5 计算配置原文的MD5
+func contentMD5(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// layersMD5 计算多层配置的整体MD5，单层时与该层原文的MD5一致
+func layersMD5(contents []string) string {
+	if len(contents) == 1 {
+		return contentMD5(contents[0])
+	}
+	sums := make([]string, len(contents))
+	for i, content := range contents {
+		sums[i] = contentMD5(content)
+	}
+	return contentMD5(strings.Join(sums, ","))
+}
+
+// writeSnapshotFile 原子地写入快照文件：先写临时文件再重命名，避免进程崩溃留下半个文件
+// 配置里可能有密码等敏感信息，文件权限为0600
+func writeSnapshotFile(path string, snapshot configSnapshotFile) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readSnapshotFile 读取快照文件并校验MD5
+func readSnapshotFile(path string) (*configSnapshotFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot configSnapshotFile
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("快照文件%s格式错误: %v", path, err)
+	}
+	for _, layer := range snapshot.Layers {
+		if contentMD5(layer.Content) != layer.MD5 {
+			return nil, fmt.Errorf("快照文件%s中[%s/%s]的MD5校验失败", path, layer.Group, layer.DataId)
+		}
+	}
+	return &snapshot, nil
+}
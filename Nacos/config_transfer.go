@@ -0,0 +1,431 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// 整个命名空间的配置导出/导入，用于克隆命名空间(例如 public -> staging)
+// 导出格式(目录或tar归档，文件名以.tar.gz/.tgz结尾时使用gzip压缩):
+//   manifest.json          元信息：每个配置的dataId、group、类型、md5、描述
+//   <group>/<dataId>       配置原文
+// nacos-sdk-go v1.1.4 的SearchConfig不返回配置类型和描述，类型按dataId的扩展名推断，
+// 推断不出时留空，导入时不指定类型；描述字段保留在清单中便于人工补充，但PublishConfig无法写回描述
+
+const (
+	manifestFile    = "manifest.json"
+	exportPageSize  = 100
+	exportMaxPages  = 1000 //防止服务端分页信息异常时无限循环
+	archiveFileMode = 0644
+	archiveDirMode  = 0755
+)
+
+// ConflictPolicy 导入时目标命名空间已存在同名配置且内容不同的处理方式
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"      //保留目标命名空间中的配置
+	ConflictOverwrite ConflictPolicy = "overwrite" //用导出的内容覆盖
+	ConflictAbort     ConflictPolicy = "abort"     //存在任何冲突时整体放弃，不发布任何配置
+)
+
+// ErrImportConflict 冲突策略为abort时存在冲突
+var ErrImportConflict = errors.New("目标命名空间中存在内容不同的同名配置")
+
+// ParseConflictPolicy 解析冲突策略
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case ConflictSkip, ConflictOverwrite, ConflictAbort:
+		return policy, nil
+	}
+	return "", fmt.Errorf("未知的冲突策略%q，可选: skip, overwrite, abort", s)
+}
+
+// ConfigArchive 一个命名空间的导出结果
+type ConfigArchive struct {
+	Namespace  string          `json:"namespace"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Items      []*ArchivedItem `json:"items"`
+}
+
+// ArchivedItem 导出的一份配置
+type ArchivedItem struct {
+	DataId      string `json:"dataId"`
+	Group       string `json:"group"`
+	Type        string `json:"type,omitempty"` //为空表示类型未知
+	MD5         string `json:"md5"`
+	Description string `json:"description,omitempty"`
+	AppName     string `json:"appName,omitempty"`
+	File        string `json:"file"` //原文在归档中的相对路径
+	Content     string `json:"-"`    //原文单独存放，不写进清单
+}
+
+// ExportNamespace 导出客户端所在命名空间的全部配置，namespace仅记录在清单中
+func ExportNamespace(client config_client.IConfigClient, namespace string) (*ConfigArchive, error) {
+	archive := &ConfigArchive{Namespace: namespace, ExportedAt: time.Now()}
+	seen := make(map[string]bool)
+	for pageNo := 1; pageNo <= exportMaxPages; pageNo++ {
+		page, err := client.SearchConfig(vo.SearchConfigParam{
+			Search:   "blur",
+			PageNo:   pageNo,
+			PageSize: exportPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("查询第%d页配置失败: %v", pageNo, err)
+		}
+		if page == nil || len(page.PageItems) == 0 {
+			break
+		}
+		for _, config := range page.PageItems {
+			key := config.Group + "/" + config.DataId
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			item := &ArchivedItem{
+				DataId:  config.DataId,
+				Group:   config.Group,
+				Type:    archiveConfigType(config.DataId),
+				MD5:     contentMD5(config.Content),
+				AppName: config.Appname,
+				File:    archiveFileName(config.Group, config.DataId),
+				Content: config.Content,
+			}
+			if config.Md5 != "" && config.Md5 != item.MD5 {
+				return nil, fmt.Errorf("配置[%s]的MD5与服务端不一致，可能在导出过程中被修改，请重试", key)
+			}
+			archive.Items = append(archive.Items, item)
+		}
+		if page.PagesAvailable > 0 && pageNo >= page.PagesAvailable {
+			break
+		}
+	}
+	archive.sortItems()
+	return archive, nil
+}
+
+// ImportResult 导入一份配置的结果
+type ImportResult struct {
+	DataId string
+	Group  string
+	Action string //created、overwritten、skipped、unchanged
+}
+
+// 导入动作
+const (
+	ImportCreated     = "created"
+	ImportOverwritten = "overwritten"
+	ImportSkipped     = "skipped"
+	ImportUnchanged   = "unchanged"
+)
+
+// ImportNamespace 把归档导入客户端所在的命名空间
+// 策略为abort时先检查全部配置，存在冲突则不发布任何配置；dryRun只计算结果不发布
+func ImportNamespace(client config_client.IConfigClient, archive *ConfigArchive, policy ConflictPolicy, dryRun bool) ([]ImportResult, error) {
+	if _, err := ParseConflictPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+
+	// 先读取目标命名空间的现状，确定每份配置的动作
+	results := make([]ImportResult, len(archive.Items))
+	var conflicts []string
+	for i, item := range archive.Items {
+		if contentMD5(item.Content) != item.MD5 {
+			return nil, fmt.Errorf("配置[%s/%s]的MD5校验失败，归档可能已损坏", item.Group, item.DataId)
+		}
+		current, err := client.GetConfig(vo.ConfigParam{DataId: item.DataId, Group: item.Group})
+		if err != nil {
+			return nil, fmt.Errorf("读取目标配置[%s/%s]失败: %v", item.Group, item.DataId, err)
+		}
+
+		result := ImportResult{DataId: item.DataId, Group: item.Group}
+		switch {
+		case current == "":
+			result.Action = ImportCreated
+		case contentMD5(current) == item.MD5:
+			result.Action = ImportUnchanged
+		case policy == ConflictOverwrite:
+			result.Action = ImportOverwritten
+		default:
+			result.Action = ImportSkipped
+			conflicts = append(conflicts, item.Group+"/"+item.DataId)
+		}
+		results[i] = result
+	}
+	if policy == ConflictAbort && len(conflicts) > 0 {
+		return results, fmt.Errorf("%w: %s", ErrImportConflict, strings.Join(conflicts, ", "))
+	}
+	if dryRun {
+		return results, nil
+	}
+
+	for i, item := range archive.Items {
+		if results[i].Action != ImportCreated && results[i].Action != ImportOverwritten {
+			continue
+		}
+		ok, err := client.PublishConfig(vo.ConfigParam{
+			DataId:  item.DataId,
+			Group:   item.Group,
+			Type:    vo.ConfigType(item.Type), //为空时SDK不传type参数
+			Content: item.Content,
+		})
+		if err == nil && !ok {
+			err = errors.New("服务端返回失败")
+		}
+		if err != nil {
+			return results[:i], fmt.Errorf("发布配置[%s/%s]失败，之前的配置已导入: %v", item.Group, item.DataId, err)
+		}
+	}
+	return results, nil
+}
+
+// WriteConfigArchive 写出归档，path以.tar、.tar.gz或.tgz结尾时写成tar归档，否则写成目录
+func WriteConfigArchive(archive *ConfigArchive, path string) error {
+	if isTarPath(path) {
+		return archive.writeTar(path)
+	}
+	return archive.writeDir(path)
+}
+
+// ReadConfigArchive 读取目录或tar归档，并校验每份配置的MD5
+func ReadConfigArchive(path string) (*ConfigArchive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	if info.IsDir() {
+		err = readArchiveDir(path, files)
+	} else {
+		err = readArchiveTar(path, files)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, ok := files[manifestFile]
+	if !ok {
+		return nil, fmt.Errorf("%s中缺少%s", path, manifestFile)
+	}
+	var archive ConfigArchive
+	if err := json.Unmarshal(manifest, &archive); err != nil {
+		return nil, fmt.Errorf("%s格式错误: %v", manifestFile, err)
+	}
+	for _, item := range archive.Items {
+		content, ok := files[item.File]
+		if !ok {
+			return nil, fmt.Errorf("归档中缺少配置[%s/%s]的文件%s", item.Group, item.DataId, item.File)
+		}
+		item.Content = string(content)
+		if contentMD5(item.Content) != item.MD5 {
+			return nil, fmt.Errorf("配置[%s/%s]的MD5校验失败，文件%s可能被修改过", item.Group, item.DataId, item.File)
+		}
+		if item.Type == "" {
+			item.Type = archiveConfigType(item.DataId)
+		}
+	}
+	archive.sortItems()
+	return &archive, nil
+}
+
+// sortItems 按group和dataId排序，保证导出结果稳定
+func (a *ConfigArchive) sortItems() {
+	sort.Slice(a.Items, func(i, j int) bool {
+		if a.Items[i].Group != a.Items[j].Group {
+			return a.Items[i].Group < a.Items[j].Group
+		}
+		return a.Items[i].DataId < a.Items[j].DataId
+	})
+}
+
+// manifest 清单文件内容
+func (a *ConfigArchive) manifest() ([]byte, error) {
+	return json.MarshalIndent(a, "", "  ")
+}
+
+// writeDir 写成目录
+func (a *ConfigArchive) writeDir(dir string) error {
+	manifest, err := a.manifest()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, archiveDirMode); err != nil {
+		return err
+	}
+	for _, item := range a.Items {
+		file := filepath.Join(dir, filepath.FromSlash(item.File))
+		if err := os.MkdirAll(filepath.Dir(file), archiveDirMode); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(item.Content), archiveFileMode); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), manifest, archiveFileMode)
+}
+
+// writeTar 写成tar归档
+func (a *ConfigArchive) writeTar(name string) (err error) {
+	manifest, err := a.manifest()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var w io.Writer = file
+	if isGzipPath(name) {
+		gz := gzip.NewWriter(file)
+		defer func() {
+			if closeErr := gz.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer func() {
+		if closeErr := tw.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: archiveFileMode, Size: int64(len(data)), ModTime: a.ExportedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(manifestFile, manifest); err != nil {
+		return err
+	}
+	for _, item := range a.Items {
+		if err := write(item.File, []byte(item.Content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readArchiveDir 读取目录中的全部文件
+func readArchiveDir(dir string, files map[string][]byte) error {
+	return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+}
+
+// readArchiveTar 读取tar归档中的全部文件
+func readArchiveTar(name string, files map[string][]byte) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if isGzipPath(name) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%s不是有效的gzip文件: %v", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取tar归档%s失败: %v", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		clean := path.Clean(header.Name)
+		if !filepath.IsLocal(clean) {
+			return fmt.Errorf("tar归档中的文件名%q不合法", header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files[clean] = data
+	}
+}
+
+// archiveConfigType 按dataId的扩展名推断配置类型；与ConfigTypeOfFile不同，没有扩展名或扩展名未知时返回空，
+// 避免把类型未知的配置导入成yaml或text
+func archiveConfigType(dataId string) string {
+	ext := strings.TrimPrefix(strings.ToLower(path.Ext(dataId)), ".")
+	if ext == "" {
+		return ""
+	}
+	if _, err := LookupDecoder(ext); err != nil {
+		return ""
+	}
+	return normalizeConfigType(ext)
+}
+
+// archiveFileName 配置原文在归档中的路径
+func archiveFileName(group, dataId string) string {
+	return path.Join(sanitizeFileName(group), sanitizeFileName(dataId))
+}
+
+// sanitizeFileName 把可能跳出目录的字符替换掉，dataId和group正常情况下不会包含这些字符
+func sanitizeFileName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name
+}
+
+// isTarPath 是否按tar归档处理
+func isTarPath(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".tar") || isGzipPath(name)
+}
+
+// isGzipPath 是否为gzip压缩的tar归档
+func isGzipPath(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// publishFake 向FakeConfigClient发布一组配置，key为 group/dataId
+func publishFake(t *testing.T, client *FakeConfigClient, configs map[[2]string]string) {
+	t.Helper()
+	for key, content := range configs {
+		if _, err := client.PublishConfig(vo.ConfigParam{Group: key[0], DataId: key[1], Content: content}); err != nil {
+			t.Fatalf("发布%s/%s失败: %v", key[0], key[1], err)
+		}
+	}
+}
+
+// fakeConfigType 读取FakeConfigClient中保存的配置类型
+func fakeConfigType(client *FakeConfigClient, dataId, group string) vo.ConfigType {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if config, ok := client.configs[fakeKey(dataId, group)]; ok {
+		return config.configType
+	}
+	return ""
+}
+
+func TestExportNamespaceInfersKnownTypesOnly(t *testing.T) {
+	client := NewFakeConfigClient("public")
+	publishFake(t, client, map[[2]string]string{
+		{"app", "db.yaml"}:      "url: x\n",
+		{"app", "flags.json"}:   `{"a": true}`,
+		{"app", "dataId"}:       "appName: a\n",
+		{"app", "rules.drools"}: "rule x",
+	})
+
+	archive, err := ExportNamespace(client, "public")
+	if err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	want := map[string]string{"db.yaml": "yaml", "flags.json": "json", "dataId": "", "rules.drools": ""}
+	if len(archive.Items) != len(want) {
+		t.Fatalf("导出了%d份配置，期望%d份", len(archive.Items), len(want))
+	}
+	for _, item := range archive.Items {
+		if item.Type != want[item.DataId] {
+			t.Errorf("%s的类型为%q，期望%q", item.DataId, item.Type, want[item.DataId])
+		}
+	}
+
+	for _, name := range []string{"dir", "public.tar.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := WriteConfigArchive(archive, path); err != nil {
+			t.Fatalf("写出%s失败: %v", name, err)
+		}
+		read, err := ReadConfigArchive(path)
+		if err != nil {
+			t.Fatalf("读取%s失败: %v", name, err)
+		}
+		for i, item := range read.Items {
+			if item.DataId != archive.Items[i].DataId || item.Content != archive.Items[i].Content || item.Type != archive.Items[i].Type {
+				t.Errorf("%s读回的配置%+v与导出的%+v不一致", name, *item, *archive.Items[i])
+			}
+		}
+	}
+}
+
+func TestImportNamespaceConflictPolicies(t *testing.T) {
+	source := NewFakeConfigClient("public")
+	publishFake(t, source, map[[2]string]string{
+		{"app", "same.yaml"}:     "a: 1\n",
+		{"app", "conflict.yaml"}: "a: 2\n",
+		{"app", "dataId"}:        "a: 3\n",
+	})
+	archive, err := ExportNamespace(source, "public")
+	if err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	tests := []struct {
+		policy       ConflictPolicy
+		wantErr      error
+		wantActions  map[string]string
+		wantConflict string //导入后conflict.yaml的内容
+	}{
+		{
+			policy:       ConflictSkip,
+			wantActions:  map[string]string{"same.yaml": ImportUnchanged, "conflict.yaml": ImportSkipped, "dataId": ImportCreated},
+			wantConflict: "a: old\n",
+		},
+		{
+			policy:       ConflictOverwrite,
+			wantActions:  map[string]string{"same.yaml": ImportUnchanged, "conflict.yaml": ImportOverwritten, "dataId": ImportCreated},
+			wantConflict: "a: 2\n",
+		},
+		{
+			policy:       ConflictAbort,
+			wantErr:      ErrImportConflict,
+			wantActions:  map[string]string{"same.yaml": ImportUnchanged, "conflict.yaml": ImportSkipped, "dataId": ImportCreated},
+			wantConflict: "a: old\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			target := NewFakeConfigClient("staging")
+			publishFake(t, target, map[[2]string]string{
+				{"app", "same.yaml"}:     "a: 1\n",
+				{"app", "conflict.yaml"}: "a: old\n",
+			})
+
+			results, err := ImportNamespace(target, archive, tt.policy, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("导入返回%v，期望%v", err, tt.wantErr)
+			}
+			for _, result := range results {
+				if result.Action != tt.wantActions[result.DataId] {
+					t.Errorf("%s的动作为%s，期望%s", result.DataId, result.Action, tt.wantActions[result.DataId])
+				}
+			}
+
+			conflict, _ := target.GetConfig(vo.ConfigParam{DataId: "conflict.yaml", Group: "app"})
+			if conflict != tt.wantConflict {
+				t.Errorf("conflict.yaml的内容为%q，期望%q", conflict, tt.wantConflict)
+			}
+			created, _ := target.GetConfig(vo.ConfigParam{DataId: "dataId", Group: "app"})
+			if tt.wantErr != nil {
+				if created != "" {
+					t.Errorf("abort时不应发布任何配置，dataId的内容为%q", created)
+				}
+				return
+			}
+			if created != "a: 3\n" {
+				t.Errorf("dataId的内容为%q，期望被创建", created)
+			}
+			// 类型未知的配置发布时不指定类型
+			if configType := fakeConfigType(target, "dataId", "app"); configType != "" {
+				t.Errorf("dataId发布时的类型为%q，期望为空", configType)
+			}
+		})
+	}
+}
+
+func TestImportNamespaceDryRun(t *testing.T) {
+	source := NewFakeConfigClient("public")
+	publishFake(t, source, map[[2]string]string{{"app", "new.yaml"}: "a: 1\n"})
+	archive, err := ExportNamespace(source, "public")
+	if err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	target := NewFakeConfigClient("staging")
+	results, err := ImportNamespace(target, archive, ConflictAbort, true)
+	if err != nil {
+		t.Fatalf("dry-run导入失败: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ImportCreated {
+		t.Fatalf("dry-run结果为%+v，期望一份created", results)
+	}
+	if content, _ := target.GetConfig(vo.ConfigParam{DataId: "new.yaml", Group: "app"}); content != "" {
+		t.Errorf("dry-run不应发布配置，实际内容为%q", content)
+	}
+}
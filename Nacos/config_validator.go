@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 配置校验：结构体validate标签 + 业务自定义校验函数
+// 支持的标签规则：
+//   required      字段不能为零值(切片/map不能为空)
+//   min=N,max=N   数字比较取值，字符串/切片/map比较长度
+//   oneof=a b c   取值必须是列出的值之一
+
+// ConfigValidator 自定义配置校验函数，返回非nil表示配置不可用
+type ConfigValidator[T any] func(config *T) error
+
+// Validatable 配置结构体可以自己实现Validate方法参与校验
+type Validatable interface {
+	Validate() error
+}
+
+// ValidationError 单个字段的校验失败信息
+type ValidationError struct {
+	Path    string //字段路径，例如 database.url
+	Rule    string //触发的规则，例如 required
+	Message string //说明
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors 一次校验中收集到的全部失败
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return "配置校验失败: " + strings.Join(msgs, "; ")
+}
+
+// ConfigRejectedError 一次配置推送被拒绝，上一份有效配置继续生效
+type ConfigRejectedError struct {
+	DataId string
+	Group  string
+	Stage  string //decode、override、secret 或 validate
+	Err    error
+}
+
+func (e *ConfigRejectedError) Error() string {
+	return fmt.Sprintf("配置[%s/%s]在%s阶段被拒绝，保留上一份有效配置: %v", e.Group, e.DataId, e.Stage, e.Err)
+}
+
+func (e *ConfigRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrorListener 配置错误监听器，配置被拒绝或监听异常时回调
+type ConfigErrorListener interface {
+	OnConfigError(err error)
+}
+
+// ConfigErrorListenerFunc 函数形式的配置错误监听器
+type ConfigErrorListenerFunc func(err error)
+
+// OnConfigError 实现ConfigErrorListener接口
+func (f ConfigErrorListenerFunc) OnConfigError(err error) {
+	f(err)
+}
+
+// validateConfig 依次执行标签校验、Validate方法和自定义校验函数
+func validateConfig[T any](config *T, validators []ConfigValidator[T]) error {
+	var errs ValidationErrors
+	collectTagErrors(reflect.ValueOf(config).Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if v, ok := interface{}(config).(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, validator := range validators {
+		if err := validator(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectTagErrors 递归检查validate标签
+func collectTagErrors(v reflect.Value, path string, errs *ValidationErrors) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			collectTagErrors(v.Elem(), path, errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fieldPath := joinPath(path, configFieldName(f))
+			if tag := f.Tag.Get("validate"); tag != "" && tag != "-" {
+				for _, rule := range strings.Split(tag, ",") {
+					if err := checkRule(v.Field(i), fieldPath, strings.TrimSpace(rule)); err != nil {
+						*errs = append(*errs, err)
+					}
+				}
+			}
+			collectTagErrors(v.Field(i), fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectTagErrors(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			collectTagErrors(iter.Value(), joinPath(path, fmt.Sprint(iter.Key().Interface())), errs)
+		}
+	}
+}
+
+// checkRule 检查单条规则
+func checkRule(v reflect.Value, path, rule string) *ValidationError {
+	name, arg, _ := strings.Cut(rule, "=")
+	fail := func(format string, args ...interface{}) *ValidationError {
+		return &ValidationError{Path: path, Rule: name, Message: fmt.Sprintf(format, args...)}
+	}
+
+	switch name {
+	case "":
+		return nil
+	case "required":
+		if isEmptyValue(v) {
+			return fail("不能为空")
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fail("规则%s的参数%q不是数字", rule, arg)
+		}
+		// 可选字段使用指针表示，未设置时不检查取值范围
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		actual, ok := measure(v)
+		if !ok {
+			return fail("规则%s不适用于%s类型", name, v.Type())
+		}
+		if name == "min" && actual < limit {
+			return fail("不能小于%s, 实际为%v", arg, actual)
+		}
+		if name == "max" && actual > limit {
+			return fail("不能大于%s, 实际为%v", arg, actual)
+		}
+	case "oneof":
+		actual := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(arg) {
+			if actual == option {
+				return nil
+			}
+		}
+		return fail("必须是[%s]之一, 实际为%q", arg, actual)
+	default:
+		return fail("未知的校验规则%q", name)
+	}
+	return nil
+}
+
+// isEmptyValue 判断字段是否为空
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// measure 数字取值本身，字符串/集合取长度
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
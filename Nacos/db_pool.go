@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 随配置热更新的数据库连接池
+// Database中的连接信息(驱动、地址、用户名、密码)变化时，先用新配置建立连接池并Ping通，
+// 成功后再原子地切换，旧连接池等正在执行的查询结束(或超时)后关闭；新配置连不上时保留旧连接池
+// 只有连接数上限变化时直接在当前连接池上调整，不重建连接
+// 使用方每次执行查询前都应调用DB()获取当前连接池，不要长期持有返回的*sql.DB
+
+const (
+	defaultDBPingTimeout  = 5 * time.Second        //新连接池Ping的超时时间
+	defaultDBDrainTimeout = 30 * time.Second       //旧连接池等待查询结束的最长时间
+	defaultDBConfigPath   = "database"             //数据库设置在配置中的默认路径
+	dbDrainGrace          = time.Second            //切换后至少保留旧连接池的时间，让刚取到旧连接池的调用能正常开始
+	dbDrainPollInterval   = 100 * time.Millisecond //检查旧连接池是否空闲的间隔
+)
+
+// ErrDBPoolClosed 连接池已停止
+var ErrDBPoolClosed = errors.New("数据库连接池已停止")
+
+// DBOpener 根据驱动名和DSN创建连接池，默认为sql.Open
+type DBOpener func(driver, dsn string) (*sql.DB, error)
+
+// DBHealth 连接池的健康状态
+type DBHealth struct {
+	Healthy    bool        //当前连接池能否Ping通
+	Driver     string      //驱动名
+	DSN        string      //连接串，密码已脱敏
+	Generation int         //连接池代次，每次切换加1
+	OpenedAt   time.Time   //当前连接池的创建时间
+	Draining   int         //正在排空的旧连接池数量
+	PingError  string      //本次Ping失败的原因
+	LastError  string      //最近一次切换失败的原因，切换成功后清空
+	Stats      sql.DBStats //当前连接池的统计信息
+}
+
+// DBPool 由配置驱动的数据库连接池
+type DBPool struct {
+	current      atomic.Pointer[dbHandle] //当前连接池，读取时无需加锁
+	mutex        sync.Mutex               //串行化切换和停止
+	waitGroup    sync.WaitGroup           //跟踪正在排空的旧连接池
+	draining     atomic.Int32             //正在排空的旧连接池数量
+	lastError    atomic.Pointer[string]   //最近一次切换失败的原因
+	generation   int                      //最近一次分配的代次，受mutex保护
+	running      bool                     //是否正在运行，受mutex保护
+	subscription *Subscription            //配置变更订阅
+	opener       DBOpener                 //创建连接池的函数
+	pingTimeout  time.Duration            //新连接池Ping的超时时间
+	drainTimeout time.Duration            //旧连接池的最长排空时间
+	configPath   string                   //数据库设置在配置中的路径，只关注该路径下的字段变更
+
+	load      func() (Database, bool)                                  //读取当前配置中的数据库设置
+	subscribe func(path string, onChange func(Database)) *Subscription //订阅配置变更
+	report    func(error)                                              //把切换失败上报给配置管理器的错误监听器
+}
+
+// dbHandle 一代连接池及其对应的配置
+type dbHandle struct {
+	db         *sql.DB
+	settings   Database
+	driver     string
+	dsn        string
+	generation int
+	openedAt   time.Time
+}
+
+// NewDBPool 创建由配置管理器驱动的连接池，selectDB从配置中取出数据库设置，
+// 调用Start后才会建立连接
+func NewDBPool[T any](cm *ConfigManager[T], selectDB func(*T) Database) *DBPool {
+	return &DBPool{
+		opener:       sql.Open,
+		pingTimeout:  defaultDBPingTimeout,
+		drainTimeout: defaultDBDrainTimeout,
+		configPath:   defaultDBConfigPath,
+		load: func() (Database, bool) {
+			// 还没有生效过任何配置时GetConfig返回零值，不能用来建立连接
+			if cm.Status().LoadedAt.IsZero() {
+				return Database{}, false
+			}
+			return selectDB(cm.GetConfig()), true
+		},
+		subscribe: func(path string, onChange func(Database)) *Subscription {
+			// 回放注册时的配置，避免Start读取配置与注册监听器之间的更新被遗漏
+			opts := []ListenerOption{WithReplay(), WithName("db-pool")}
+			if path != "" {
+				opts = append(opts, WithPaths(path+".*"))
+			}
+			return cm.AddListener(ConfigChangeListenerFunc[T](func(event *ConfigChangeEvent[T]) {
+				onChange(selectDB(event.New))
+			}), opts...)
+		},
+		report: cm.reportError,
+	}
+}
+
+// SetOpener 设置创建连接池的函数，可以用于接入自定义驱动或在测试中注入假驱动
+func (p *DBPool) SetOpener(opener DBOpener) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.opener = opener
+}
+
+// SetPingTimeout 设置新连接池Ping的超时时间
+func (p *DBPool) SetPingTimeout(timeout time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.pingTimeout = timeout
+}
+
+// SetDrainTimeout 设置旧连接池等待查询结束的最长时间，超时后强制关闭
+func (p *DBPool) SetDrainTimeout(timeout time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.drainTimeout = timeout
+}
+
+// SetConfigPath 设置selectDB取出的数据库设置在配置中的路径(默认为database)，只有该路径下的字段变化时才检查是否需要切换；
+// 为空表示任意字段变化都检查。需要在Start之前调用
+func (p *DBPool) SetConfigPath(path string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.configPath = path
+}
+
+// Start 按当前配置建立连接池并订阅配置变更，配置管理器需要已经加载过配置
+func (p *DBPool) Start(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.running {
+		return nil
+	}
+	settings, ok := p.load()
+	if !ok {
+		return errors.New("配置尚未加载，请先启动配置管理器")
+	}
+	handle, err := p.open(ctx, settings)
+	if err != nil {
+		return err
+	}
+	p.current.Store(handle)
+	p.running = true
+	p.subscription = p.subscribe(p.configPath, p.reload)
+
+	logf("数据库连接池已建立: %s %s\n", handle.driver, redactDSN(handle.dsn))
+	return nil
+}
+
+// DB 返回当前的连接池，停止后返回nil
+func (p *DBPool) DB() *sql.DB {
+	if handle := p.current.Load(); handle != nil {
+		return handle.db
+	}
+	return nil
+}
+
+// Health 探测当前连接池并返回健康状态
+func (p *DBPool) Health(ctx context.Context) DBHealth {
+	health := DBHealth{Draining: int(p.draining.Load())}
+	if lastError := p.lastError.Load(); lastError != nil {
+		health.LastError = *lastError
+	}
+	handle := p.current.Load()
+	if handle == nil {
+		health.PingError = ErrDBPoolClosed.Error()
+		return health
+	}
+
+	health.Driver = handle.driver
+	health.DSN = redactDSN(handle.dsn)
+	health.Generation = handle.generation
+	health.OpenedAt = handle.openedAt
+	health.Stats = handle.db.Stats()
+	if err := handle.db.PingContext(ctx); err != nil {
+		health.PingError = err.Error()
+		return health
+	}
+	health.Healthy = true
+	return health
+}
+
+// Stop 取消订阅并关闭所有连接池，ctx到期时不再等待旧连接池排空
+func (p *DBPool) Stop(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.running {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.running = false
+	p.subscription.Unsubscribe()
+	// 当前连接池同样先排空再关闭
+	p.retire(p.current.Swap(nil))
+	p.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.waitGroup.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("等待数据库连接池关闭超时: %w", ctx.Err())
+	}
+
+	logf("数据库连接池已关闭\n")
+	return nil
+}
+
+// reload 配置变更回调，连接信息变化时切换到新连接池
+func (p *DBPool) reload(settings Database) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.running {
+		return
+	}
+	current := p.current.Load()
+	if reflect.DeepEqual(current.settings, settings) {
+		return
+	}
+	if sameDataSource(current.settings, settings) {
+		applyPoolLimits(current.db, settings)
+		current.settings = settings
+		logf("数据库连接池参数已更新: maxOpenConns=%d maxIdleConns=%d\n", settings.MaxOpenConns, settings.MaxIdleConns)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.pingTimeout)
+	defer cancel()
+	handle, err := p.open(ctx, settings)
+	if err != nil {
+		err = fmt.Errorf("切换数据库连接池失败，继续使用旧连接池: %v", err)
+		message := err.Error()
+		p.lastError.Store(&message)
+		logf("%s\n", message)
+		p.report(err)
+		return
+	}
+
+	p.current.Store(handle)
+	p.lastError.Store(nil)
+	p.retire(current)
+	logf("数据库连接池已切换到第%d代: %s %s\n", handle.generation, handle.driver, redactDSN(handle.dsn))
+}
+
+// open 按配置创建连接池并Ping通，调用方需持有mutex
+func (p *DBPool) open(ctx context.Context, settings Database) (*dbHandle, error) {
+	driver, dsn, err := settings.DataSource()
+	if err != nil {
+		return nil, err
+	}
+	db, err := p.opener(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库连接池失败(%s %s): %v", driver, redactDSN(dsn), err)
+	}
+	applyPoolLimits(db, settings)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接数据库失败(%s %s): %v", driver, redactDSN(dsn), err)
+	}
+
+	p.generation++
+	return &dbHandle{
+		db:         db,
+		settings:   settings,
+		driver:     driver,
+		dsn:        dsn,
+		generation: p.generation,
+		openedAt:   time.Now(),
+	}, nil
+}
+
+// retire 在后台排空并关闭旧连接池，调用方需持有mutex
+func (p *DBPool) retire(handle *dbHandle) {
+	if handle == nil {
+		return
+	}
+	p.waitGroup.Add(1)
+	p.draining.Add(1)
+	timeout := p.drainTimeout
+	go func() {
+		defer p.waitGroup.Done()
+		defer p.draining.Add(-1)
+
+		time.Sleep(dbDrainGrace)
+		deadline := time.Now().Add(timeout)
+		for handle.db.Stats().InUse > 0 && time.Now().Before(deadline) {
+			time.Sleep(dbDrainPollInterval)
+		}
+		if inUse := handle.db.Stats().InUse; inUse > 0 {
+			logf("第%d代数据库连接池排空超时，仍有%d个连接在使用，强制关闭\n", handle.generation, inUse)
+		}
+		if err := handle.db.Close(); err != nil {
+			logf("关闭第%d代数据库连接池失败: %v\n", handle.generation, err)
+		}
+	}()
+}
+
+// applyPoolLimits 设置连接数上限，0表示使用database/sql的默认值
+func applyPoolLimits(db *sql.DB, settings Database) {
+	db.SetMaxOpenConns(settings.MaxOpenConns)
+	if settings.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(settings.MaxIdleConns)
+	}
+}
+
+// sameDataSource 连接信息是否相同，相同时只需要调整连接数上限
+func sameDataSource(a, b Database) bool {
+	return a.Driver == b.Driver && a.Url == b.Url && a.Username == b.Username && a.Password == b.Password
+}
+
+// DataSource 根据配置得到驱动名和DSN
+// Url兼容JDBC写法(jdbc:mysql://host:3306/db)，Driver为空时按Url的协议推断驱动；
+// mysql转换为go-sql-driver的 user:password@tcp(host:port)/db 格式，
+// 其他带协议的Url(例如postgres://)把用户名和密码写入Url，不带协议的Url原样作为DSN
+func (d Database) DataSource() (string, string, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(d.Url), "jdbc:")
+	driver := d.Driver
+	if driver == "" {
+		scheme, _, ok := strings.Cut(raw, "://")
+		if !ok {
+			return "", "", fmt.Errorf("无法从数据库地址%q推断驱动，请配置database.driver", RedactSecrets(d.Url))
+		}
+		driver = scheme
+	}
+	if !strings.Contains(raw, "://") {
+		return driver, raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("数据库地址格式错误: %v", err)
+	}
+	username, password := d.Username, d.Password
+	if u.User != nil && username == "" {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	if driver == "mysql" {
+		var dsn strings.Builder
+		if username != "" {
+			dsn.WriteString(username)
+			if password != "" {
+				dsn.WriteString(":" + password)
+			}
+			dsn.WriteString("@")
+		}
+		fmt.Fprintf(&dsn, "tcp(%s)%s", u.Host, u.Path)
+		if u.RawQuery != "" {
+			dsn.WriteString("?" + u.RawQuery)
+		}
+		return driver, dsn.String(), nil
+	}
+
+	if username != "" {
+		if password != "" {
+			u.User = url.UserPassword(username, password)
+		} else {
+			u.User = url.User(username)
+		}
+	}
+	return driver, u.String(), nil
+}
+
+// redactDSN 隐藏DSN中的密码，兼容URL和 user:password@tcp(...) 两种格式
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		return strings.Replace(u.Redacted(), ":xxxxx@", ":"+redactedValue+"@", 1)
+	}
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return RedactSecrets(dsn)
+	}
+	if user, _, ok := strings.Cut(dsn[:at], ":"); ok {
+		return user + ":" + redactedValue + dsn[at:]
+	}
+	return dsn
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver 测试用的database/sql驱动，按DSN控制能否连通
+type fakeDriver struct {
+	mutex   sync.Mutex
+	failing map[string]bool
+}
+
+func newFakeDriver() *fakeDriver {
+	return &fakeDriver{failing: make(map[string]bool)}
+}
+
+// setFailing 设置该DSN的连接和Ping是否失败
+func (d *fakeDriver) setFailing(dsn string, failing bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.failing[dsn] = failing
+}
+
+// check 该DSN当前能否连通
+func (d *fakeDriver) check(dsn string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.failing[dsn] {
+		return fmt.Errorf("连接%s被拒绝", dsn)
+	}
+	return nil
+}
+
+// opener 作为DBPool的DBOpener，不需要向database/sql注册驱动
+func (d *fakeDriver) opener(driverName, dsn string) (*sql.DB, error) {
+	return sql.OpenDB(&fakeConnector{driver: d, dsn: dsn}), nil
+}
+
+// fakeConnector 实现driver.Connector
+type fakeConnector struct {
+	driver *fakeDriver
+	dsn    string
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.driver.check(c.dsn); err != nil {
+		return nil, err
+	}
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriverFunc(func(name string) (driver.Conn, error) {
+		return nil, errors.New("请使用Connector")
+	})
+}
+
+// fakeDriverFunc 函数形式的driver.Driver
+type fakeDriverFunc func(name string) (driver.Conn, error)
+
+func (f fakeDriverFunc) Open(name string) (driver.Conn, error) {
+	return f(name)
+}
+
+// fakeConn 只支持Ping的连接
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn不支持执行SQL")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakeConn不支持事务")
+}
+
+// Ping 实现driver.Pinger，已建立的连接同样受failing控制
+func (c *fakeConn) Ping(ctx context.Context) error {
+	return c.connector.driver.check(c.connector.dsn)
+}
+
+// dbConfig 生成带数据库设置的yaml配置
+func dbConfig(url, username, password string, maxOpenConns int) string {
+	return fmt.Sprintf("appName: demo\nserverPort: 8080\ndatabase:\n  driver: fake\n  url: %s\n  username: %s\n  password: %s\n  maxOpenConns: %d\n",
+		url, username, password, maxOpenConns)
+}
+
+// startTestDBPool 启动配置管理器和连接池，测试结束时停止
+func startTestDBPool(t *testing.T, drv *fakeDriver, content string) (*FakeConfigClient, *ConfigManager[ConfigData], *DBPool) {
+	t.Helper()
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, content)
+	cm := startTestManager(t, client)
+
+	pool := NewDBPool(cm, func(config *ConfigData) Database { return config.Database })
+	pool.SetOpener(drv.opener)
+	pool.SetDrainTimeout(5 * time.Second)
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("启动连接池失败: %v", err)
+	}
+	t.Cleanup(func() { pool.Stop(context.Background()) })
+	return client, cm, pool
+}
+
+// waitFor 轮询直到条件满足
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// isClosed 连接池是否已经关闭
+func isClosed(db *sql.DB) bool {
+	err := db.PingContext(context.Background())
+	return err != nil && err.Error() == "sql: database is closed"
+}
+
+func TestDBPoolRequiresLoadedConfig(t *testing.T) {
+	client := NewFakeConfigClient("")
+	cm := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	pool := NewDBPool(cm, func(config *ConfigData) Database { return config.Database })
+	pool.SetOpener(newFakeDriver().opener)
+	if err := pool.Start(context.Background()); err == nil {
+		pool.Stop(context.Background())
+		t.Fatal("配置管理器没有加载过配置时应该启动失败")
+	}
+}
+
+func TestDBPoolSwapsOnDataSourceChange(t *testing.T) {
+	drv := newFakeDriver()
+	client, _, pool := startTestDBPool(t, drv, dbConfig("db1", "admin", "secret1", 10))
+	ctx := context.Background()
+
+	first := pool.DB()
+	if health := pool.Health(ctx); !health.Healthy || health.Generation != 1 || health.Stats.MaxOpenConnections != 10 {
+		t.Fatalf("初始状态不正确: %+v", health)
+	}
+
+	// 只修改连接数上限时在原连接池上调整
+	publishTestConfig(t, client, dbConfig("db1", "admin", "secret1", 20))
+	waitFor(t, "连接数上限生效", func() bool { return pool.DB().Stats().MaxOpenConnections == 20 })
+	if pool.DB() != first || pool.Health(ctx).Generation != 1 {
+		t.Fatal("只修改连接数上限时不应该重建连接池")
+	}
+
+	// 旧连接池上有正在使用的连接，切换后要等它归还才关闭
+	conn, err := first.Conn(ctx)
+	if err != nil {
+		t.Fatalf("获取连接失败: %v", err)
+	}
+	publishTestConfig(t, client, dbConfig("db2", "admin", "secret1", 20))
+	waitFor(t, "切换到新的地址", func() bool { return pool.Health(ctx).Generation == 2 })
+	if pool.DB() == first {
+		t.Fatal("地址变化后应该切换到新连接池")
+	}
+	if health := pool.Health(ctx); health.Draining != 1 || health.DSN != "db2" {
+		t.Errorf("切换后的状态为%+v，期望旧连接池正在排空", health)
+	}
+	time.Sleep(dbDrainGrace + 2*dbDrainPollInterval)
+	if isClosed(first) {
+		t.Fatal("旧连接池还有连接在使用时不应该关闭")
+	}
+	if err := conn.PingContext(ctx); err != nil {
+		t.Errorf("排空期间旧连接应该仍然可用: %v", err)
+	}
+	conn.Close()
+	waitFor(t, "旧连接池排空", func() bool { return pool.Health(ctx).Draining == 0 })
+	if !isClosed(first) {
+		t.Error("旧连接池排空后应该关闭")
+	}
+
+	// 用户名、密码变化同样切换
+	publishTestConfig(t, client, dbConfig("db2", "reader", "secret1", 20))
+	waitFor(t, "切换用户名", func() bool { return pool.Health(ctx).Generation == 3 })
+	publishTestConfig(t, client, dbConfig("db2", "reader", "secret2", 20))
+	waitFor(t, "切换密码", func() bool { return pool.Health(ctx).Generation == 4 })
+
+	// 与数据库无关的字段变化不触发检查
+	publishTestConfig(t, client, dbConfig("db2", "reader", "secret2", 20)+"features: [x]\n")
+	time.Sleep(100 * time.Millisecond)
+	if generation := pool.Health(ctx).Generation; generation != 4 {
+		t.Errorf("无关字段变化后代次为%d", generation)
+	}
+}
+
+func TestDBPoolKeepsOldPoolWhenPingFails(t *testing.T) {
+	drv := newFakeDriver()
+	drv.setFailing("broken", true)
+	client, cm, pool := startTestDBPool(t, drv, dbConfig("db1", "admin", "secret1", 10))
+	reported := make(chan error, 4)
+	cm.AddErrorListener(ConfigErrorListenerFunc(func(err error) { reported <- err }))
+	ctx := context.Background()
+	first := pool.DB()
+
+	publishTestConfig(t, client, dbConfig("broken", "admin", "secret1", 10))
+	select {
+	case err := <-reported:
+		if err == nil {
+			t.Fatal("上报的错误为nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("切换失败时应该上报给错误监听器")
+	}
+	health := pool.Health(ctx)
+	if pool.DB() != first || !health.Healthy || health.Generation != 1 || health.LastError == "" {
+		t.Fatalf("切换失败后的状态为%+v，期望继续使用旧连接池", health)
+	}
+
+	// 修正配置后切换成功，清空失败原因
+	publishTestConfig(t, client, dbConfig("db2", "admin", "secret1", 10))
+	waitFor(t, "切换到修正后的地址", func() bool { return pool.Health(ctx).Generation == 2 })
+	if health := pool.Health(ctx); health.LastError != "" {
+		t.Errorf("切换成功后LastError应该为空: %q", health.LastError)
+	}
+}
+
+func TestDBPoolHealth(t *testing.T) {
+	drv := newFakeDriver()
+	_, _, pool := startTestDBPool(t, drv, dbConfig("db1", "admin", "secret1", 10))
+	ctx := context.Background()
+
+	health := pool.Health(ctx)
+	if !health.Healthy || health.Driver != "fake" || health.DSN != "db1" || health.PingError != "" || health.OpenedAt.IsZero() {
+		t.Fatalf("健康状态不正确: %+v", health)
+	}
+
+	drv.setFailing("db1", true)
+	if health := pool.Health(ctx); health.Healthy || health.PingError == "" {
+		t.Errorf("数据库不可用时的健康状态为%+v", health)
+	}
+	drv.setFailing("db1", false)
+	if health := pool.Health(ctx); !health.Healthy {
+		t.Errorf("数据库恢复后的健康状态为%+v", health)
+	}
+
+	if err := pool.Stop(ctx); err != nil {
+		t.Fatalf("停止连接池失败: %v", err)
+	}
+	if pool.DB() != nil {
+		t.Error("停止后DB()应该返回nil")
+	}
+	if health := pool.Health(ctx); health.Healthy || health.PingError != ErrDBPoolClosed.Error() {
+		t.Errorf("停止后的健康状态为%+v", health)
+	}
+}
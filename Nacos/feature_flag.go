@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// 功能开关：由ConfigManager驱动，在Nacos上修改配置即可开关功能，不需要重新发布服务
+// features列表中的名称表示对所有人开启；需要灰度或定向的开关写在flags中，同名时以flags为准:
+//
+//	features: [login, dashboard]
+//	flags:
+//	  new-checkout:
+//	    enabled: true        # 总开关，false或不写时对所有人关闭
+//	    percentage: 20       # 没有命中任何规则的请求按20%灰度，不写表示100%
+//	    rules:               # 按顺序匹配，第一条命中的规则决定结果
+//	      - tenants: [acme]  # acme租户全部开启
+//	      - users: [u-1001]
+//	        percentage: 0    # 显式对该用户关闭
+//
+// 灰度按 开关名+用户ID(没有用户ID时用租户ID) 的哈希分桶，比例不变时同一用户的结果稳定，
+// 调大比例只会新增开启的用户，不会让已经开启的用户被关闭
+
+// FeatureFlag 一个功能开关的定义
+type FeatureFlag struct {
+	Enabled    bool          `yaml:"enabled"`                             //总开关
+	Percentage *int          `yaml:"percentage" validate:"min=0,max=100"` //没有命中规则时的灰度比例，nil表示100%
+	Rules      []FeatureRule `yaml:"rules"`                               //定向规则
+}
+
+// FeatureRule 定向规则，Users和Tenants同时配置时两者都要命中，都不配置时匹配所有请求
+type FeatureRule struct {
+	Users      []string `yaml:"users"`
+	Tenants    []string `yaml:"tenants"`
+	Percentage *int     `yaml:"percentage" validate:"min=0,max=100"` //命中规则后的灰度比例，nil表示100%
+}
+
+// FeatureContext 判断开关时的请求上下文
+type FeatureContext struct {
+	UserID   string
+	TenantID string
+}
+
+// FeatureChange 开关定义的变更，开关不存在时对应的值为零值(关闭)
+type FeatureChange struct {
+	Name string
+	Old  FeatureFlag
+	New  FeatureFlag
+}
+
+// FeatureFlags 功能开关集合，读取无锁，可以在请求路径上频繁调用
+type FeatureFlags struct {
+	flags        atomic.Pointer[map[string]FeatureFlag]
+	mutex        sync.Mutex
+	callbacks    map[string][]featureCallback
+	nextID       uint64
+	subscription *Subscription
+}
+
+// featureCallback 单个开关的变更回调
+type featureCallback struct {
+	id uint64
+	fn func(FeatureChange)
+}
+
+// NewFeatureFlags 创建功能开关集合并订阅配置变更，selectFlags从配置中取出全部开关定义，
+// 对ConfigData可以直接传 (*ConfigData).EffectiveFlags
+func NewFeatureFlags[T any](cm *ConfigManager[T], selectFlags func(*T) map[string]FeatureFlag) *FeatureFlags {
+	ff := &FeatureFlags{callbacks: make(map[string][]featureCallback)}
+	empty := map[string]FeatureFlag{}
+	ff.flags.Store(&empty)
+	if config := cm.GetConfig(); config != nil {
+		ff.update(selectFlags(config))
+	}
+
+	// 回放注册时的配置，避免GetConfig与注册监听器之间的更新被遗漏
+	ff.subscription = cm.AddListener(ConfigChangeListenerFunc[T](func(event *ConfigChangeEvent[T]) {
+		ff.update(selectFlags(event.New))
+	}), WithReplay(), WithName("feature-flags"))
+	return ff
+}
+
+// Close 取消对配置变更的订阅，之后开关保持最后一次的状态
+func (ff *FeatureFlags) Close() {
+	ff.subscription.Unsubscribe()
+}
+
+// IsEnabled 不带用户信息判断开关：只有对所有请求开启(总开关打开、比例为100%)时返回true
+func (ff *FeatureFlags) IsEnabled(name string) bool {
+	return ff.IsEnabledFor(name, FeatureContext{})
+}
+
+// IsEnabledFor 按用户和租户判断开关，未定义的开关视为关闭
+func (ff *FeatureFlags) IsEnabledFor(name string, ctx FeatureContext) bool {
+	flag, ok := (*ff.flags.Load())[name]
+	if !ok {
+		return false
+	}
+	return flag.Evaluate(name, ctx)
+}
+
+// Flag 返回开关的当前定义
+func (ff *FeatureFlags) Flag(name string) (FeatureFlag, bool) {
+	flag, ok := (*ff.flags.Load())[name]
+	return flag, ok
+}
+
+// Names 返回已定义的开关名，按字母排序
+func (ff *FeatureFlags) Names() []string {
+	flags := *ff.flags.Load()
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OnChange 注册单个开关的变更回调，开关的定义(总开关、比例或规则)变化时调用，
+// 回调在配置监听器的goroutine中按顺序执行，不要在回调中长时间阻塞
+func (ff *FeatureFlags) OnChange(name string, fn func(FeatureChange)) *Subscription {
+	ff.mutex.Lock()
+	defer ff.mutex.Unlock()
+
+	ff.nextID++
+	id := ff.nextID
+	ff.callbacks[name] = append(ff.callbacks[name], featureCallback{id: id, fn: fn})
+	return &Subscription{id: id, cancel: ff.removeCallback}
+}
+
+// removeCallback 注销回调
+func (ff *FeatureFlags) removeCallback(id uint64) bool {
+	ff.mutex.Lock()
+	defer ff.mutex.Unlock()
+
+	for name, callbacks := range ff.callbacks {
+		for i, callback := range callbacks {
+			if callback.id != id {
+				continue
+			}
+			ff.callbacks[name] = append(callbacks[:i:i], callbacks[i+1:]...)
+			if len(ff.callbacks[name]) == 0 {
+				delete(ff.callbacks, name)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// update 替换开关定义，并对定义发生变化的开关执行回调
+func (ff *FeatureFlags) update(flags map[string]FeatureFlag) {
+	if flags == nil {
+		flags = map[string]FeatureFlag{}
+	}
+	old := *ff.flags.Swap(&flags)
+
+	var changes []FeatureChange
+	for name, flag := range flags {
+		if before, ok := old[name]; !ok || !reflect.DeepEqual(before, flag) {
+			changes = append(changes, FeatureChange{Name: name, Old: before, New: flag})
+		}
+	}
+	for name, before := range old {
+		if _, ok := flags[name]; !ok {
+			changes = append(changes, FeatureChange{Name: name, Old: before})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	for _, change := range changes {
+		ff.mutex.Lock()
+		callbacks := append([]featureCallback(nil), ff.callbacks[change.Name]...)
+		ff.mutex.Unlock()
+		for _, callback := range callbacks {
+			runFeatureCallback(callback.fn, change)
+		}
+	}
+}
+
+// runFeatureCallback 执行回调，单个回调panic不影响其他回调
+func runFeatureCallback(fn func(FeatureChange), change FeatureChange) {
+	defer func() {
+		if r := recover(); r != nil {
+			logf("功能开关[%s]的变更回调发生panic: %v\n", change.Name, r)
+		}
+	}()
+	fn(change)
+}
+
+// Evaluate 按上下文计算开关结果，name参与灰度分桶
+func (f FeatureFlag) Evaluate(name string, ctx FeatureContext) bool {
+	if !f.Enabled {
+		return false
+	}
+	for _, rule := range f.Rules {
+		if rule.matches(ctx) {
+			return inRollout(name, ctx, rule.Percentage)
+		}
+	}
+	return inRollout(name, ctx, f.Percentage)
+}
+
+// String 用于日志输出
+func (f FeatureFlag) String() string {
+	if !f.Enabled {
+		return "关闭"
+	}
+	s := "开启"
+	if f.Percentage != nil {
+		s = fmt.Sprintf("灰度%d%%", *f.Percentage)
+	}
+	if len(f.Rules) > 0 {
+		s += fmt.Sprintf("(%d条定向规则)", len(f.Rules))
+	}
+	return s
+}
+
+// matches 判断规则是否命中
+func (r FeatureRule) matches(ctx FeatureContext) bool {
+	if len(r.Users) > 0 && !containsString(r.Users, ctx.UserID) {
+		return false
+	}
+	if len(r.Tenants) > 0 && !containsString(r.Tenants, ctx.TenantID) {
+		return false
+	}
+	return true
+}
+
+// inRollout 判断请求是否落在灰度比例内，没有用户和租户信息时只有100%才算命中
+func inRollout(name string, ctx FeatureContext, percentage *int) bool {
+	if percentage == nil || *percentage >= 100 {
+		return true
+	}
+	if *percentage <= 0 {
+		return false
+	}
+	key := ctx.UserID
+	if key == "" {
+		key = ctx.TenantID
+	}
+	if key == "" {
+		return false
+	}
+	return featureBucket(name, key) < *percentage
+}
+
+// featureBucket 把 开关名+key 稳定地映射到[0,100)
+func featureBucket(name, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// percent 返回比例的指针
+func percent(p int) *int {
+	return &p
+}
+
+func TestFeatureFlagEvaluate(t *testing.T) {
+	acme := FeatureContext{UserID: "u-1", TenantID: "acme"}
+	anonymous := FeatureContext{}
+	tests := []struct {
+		name string
+		flag FeatureFlag
+		ctx  FeatureContext
+		want bool
+	}{
+		{"总开关关闭", FeatureFlag{Enabled: false}, acme, false},
+		{"不写比例对所有人开启", FeatureFlag{Enabled: true}, anonymous, true},
+		{"比例100", FeatureFlag{Enabled: true, Percentage: percent(100)}, anonymous, true},
+		{"比例0", FeatureFlag{Enabled: true, Percentage: percent(0)}, acme, false},
+		{"没有用户信息时灰度不命中", FeatureFlag{Enabled: true, Percentage: percent(99)}, anonymous, false},
+		{"规则优先于比例", FeatureFlag{Enabled: true, Percentage: percent(0), Rules: []FeatureRule{{Tenants: []string{"acme"}}}}, acme, true},
+		{"规则可以显式关闭", FeatureFlag{Enabled: true, Rules: []FeatureRule{{Users: []string{"u-1"}, Percentage: percent(0)}}}, acme, false},
+		{"第一条命中的规则生效", FeatureFlag{Enabled: true, Rules: []FeatureRule{{Tenants: []string{"acme"}}, {Users: []string{"u-1"}, Percentage: percent(0)}}}, acme, true},
+		{"用户和租户都要命中", FeatureFlag{Enabled: true, Percentage: percent(0), Rules: []FeatureRule{{Users: []string{"u-1"}, Tenants: []string{"other"}}}}, acme, false},
+		{"没有命中规则时按比例", FeatureFlag{Enabled: true, Percentage: percent(0), Rules: []FeatureRule{{Users: []string{"u-2"}}}}, acme, false},
+		{"总开关关闭时规则不生效", FeatureFlag{Enabled: false, Rules: []FeatureRule{{Tenants: []string{"acme"}}}}, acme, false},
+	}
+	for _, tt := range tests {
+		if got := tt.flag.Evaluate("beta", tt.ctx); got != tt.want {
+			t.Errorf("%s: Evaluate返回%v，期望%v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFeatureFlagRolloutBuckets(t *testing.T) {
+	twenty := FeatureFlag{Enabled: true, Percentage: percent(20)}
+	fifty := FeatureFlag{Enabled: true, Percentage: percent(50)}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		ctx := FeatureContext{UserID: fmt.Sprintf("u-%d", i)}
+		got := twenty.Evaluate("beta", ctx)
+		if twenty.Evaluate("beta", ctx) != got {
+			t.Fatalf("%s两次判断的结果不一致", ctx.UserID)
+		}
+		if got {
+			enabled++
+			// 调大比例不会让已经开启的用户被关闭
+			if !fifty.Evaluate("beta", ctx) {
+				t.Errorf("%s在20%%时开启，50%%时被关闭", ctx.UserID)
+			}
+		}
+		if bucket := featureBucket("beta", ctx.UserID); bucket < 0 || bucket >= 100 {
+			t.Fatalf("%s的分桶%d超出[0,100)", ctx.UserID, bucket)
+		}
+	}
+	if enabled < 150 || enabled > 250 {
+		t.Errorf("20%%灰度下1000个用户开启了%d个", enabled)
+	}
+
+	// 没有用户ID时按租户分桶
+	ctx := FeatureContext{TenantID: "acme"}
+	if twenty.Evaluate("beta", ctx) != (featureBucket("beta", "acme") < 20) {
+		t.Error("没有用户ID时应该按租户ID分桶")
+	}
+}
+
+// flagsConfig 生成带功能开关的yaml配置
+func flagsConfig(flags string) string {
+	return appConfig("demo", 8080) + "features: [login]\nflags:\n" + flags
+}
+
+// changeRecorder 把开关变更转发到channel
+type changeRecorder chan FeatureChange
+
+func (r changeRecorder) record(change FeatureChange) {
+	r <- change
+}
+
+// next 等待下一次变更
+func (r changeRecorder) next(t *testing.T) FeatureChange {
+	t.Helper()
+	select {
+	case change := <-r:
+		return change
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待开关变更回调超时")
+		return FeatureChange{}
+	}
+}
+
+// none 确认一段时间内没有收到变更
+func (r changeRecorder) none(t *testing.T) {
+	t.Helper()
+	select {
+	case change := <-r:
+		t.Fatalf("不应收到开关%s的变更", change.Name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFeatureFlagsOnChange(t *testing.T) {
+	client := NewFakeConfigClient("")
+	publishTestConfig(t, client, flagsConfig("  beta:\n    enabled: false\n  gamma:\n    enabled: true\n"))
+	cm := startTestManager(t, client)
+	ff := NewFeatureFlags(cm, (*ConfigData).EffectiveFlags)
+	defer ff.Close()
+
+	if !ff.IsEnabled("login") || ff.IsEnabled("beta") || !ff.IsEnabled("gamma") || ff.IsEnabled("missing") {
+		t.Fatalf("初始开关状态不正确: %v", ff.Names())
+	}
+	beta, gamma := make(changeRecorder, 8), make(changeRecorder, 8)
+	betaSubscription := ff.OnChange("beta", beta.record)
+	ff.OnChange("gamma", gamma.record)
+
+	// 只有定义变化的开关回调
+	publishTestConfig(t, client, flagsConfig("  beta:\n    enabled: true\n  gamma:\n    enabled: true\n"))
+	if change := beta.next(t); change.Name != "beta" || change.Old.Enabled || !change.New.Enabled {
+		t.Errorf("beta的变更为%+v", change)
+	}
+	gamma.none(t)
+	if !ff.IsEnabled("beta") {
+		t.Error("beta应该已经开启")
+	}
+
+	// 删除开关时New为零值
+	publishTestConfig(t, client, flagsConfig("  beta:\n    enabled: true\n"))
+	if change := gamma.next(t); !change.Old.Enabled || change.New.Enabled {
+		t.Errorf("删除gamma时的变更为%+v", change)
+	}
+	beta.none(t)
+
+	// 取消订阅后不再回调
+	if !betaSubscription.Unsubscribe() {
+		t.Fatal("取消beta的回调失败")
+	}
+	publishTestConfig(t, client, flagsConfig("  beta:\n    enabled: true\n    percentage: 10\n"))
+	beta.none(t)
+
+	// Close之后开关保持最后一次的状态，也不再回调
+	ff.Close()
+	publishTestConfig(t, client, flagsConfig("  beta:\n    enabled: false\n  gamma:\n    enabled: true\n"))
+	gamma.none(t)
+	if flag, ok := ff.Flag("beta"); !ok || !flag.Enabled || flag.Percentage == nil || *flag.Percentage != 10 {
+		t.Errorf("Close之后beta的定义变成了%+v", flag)
+	}
+}
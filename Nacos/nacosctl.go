@@ -0,0 +1,312 @@
+//go:build nacosctl
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// nacosctl Nacos配置运维命令行工具
+// 用法: go run -tags nacosctl . <子命令> [参数]
+// 或者先构建: go build -tags nacosctl -o nacosctl .
+//   publish  校验并发布配置文件，例如:
+//            nacosctl publish --data-id dataId --group group --file config.yaml --dry-run
+//   export   导出整个命名空间的配置，例如:
+//            nacosctl export --namespace "" --out public.tar.gz
+//   import   把导出的配置导入另一个命名空间，例如:
+//            nacosctl import --namespace staging --in public.tar.gz --conflict skip
+//   standin  在本地启动内存版的Nacos替身服务，用于不依赖Docker的端到端调试，例如:
+//            nacosctl standin --listen :8848
+
+// configSchemas 可以通过--schema指定的目标结构体
+var configSchemas = map[string]ConfigSchema{
+	"ConfigData": SchemaOf[ConfigData](),
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "publish":
+		err = runPublish(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	case "standin":
+		err = runStandin(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "未知的子命令: %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: nacosctl <子命令> [参数]")
+	fmt.Fprintln(os.Stderr, "子命令:")
+	fmt.Fprintln(os.Stderr, "  publish  校验配置文件、展示与线上内容的差异并发布")
+	fmt.Fprintln(os.Stderr, "  export   导出整个命名空间的配置到目录或tar归档")
+	fmt.Fprintln(os.Stderr, "  import   把导出的配置导入命名空间")
+	fmt.Fprintln(os.Stderr, "  standin  启动内存版的Nacos替身服务")
+	fmt.Fprintln(os.Stderr, "使用 nacosctl <子命令> -h 查看子命令的参数")
+}
+
+// runPublish publish子命令
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	server := fs.String("server", "localhost:8848", "Nacos服务地址")
+	namespace := fs.String("namespace", "", "命名空间ID，为空表示public")
+	dataId := fs.String("data-id", "", "配置的dataId")
+	group := fs.String("group", "DEFAULT_GROUP", "配置的group")
+	configType := fs.String("type", "", "配置类型(yaml/json/properties/toml/text)，为空时按文件扩展名推断")
+	file := fs.String("file", "", "要发布的配置文件")
+	schemaName := fs.String("schema", "ConfigData", "校验使用的目标结构体，为空表示不校验，可选: "+strings.Join(schemaNames(), ", "))
+	expectMD5 := fs.String("expect-md5", "", "期望的线上内容MD5，通常取自上一次--dry-run的输出；不一致时拒绝发布")
+	dryRun := fs.Bool("dry-run", false, "只校验并展示差异，不发布")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataId == "" || *file == "" {
+		fs.Usage()
+		return errors.New("--data-id和--file是必填参数")
+	}
+
+	content, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if *configType == "" {
+		*configType = ConfigTypeOfFile(*file)
+	}
+
+	var schema ConfigSchema
+	if *schemaName != "" && normalizeConfigType(*configType) != "text" {
+		var ok bool
+		schema, ok = configSchemas[*schemaName]
+		if !ok {
+			return fmt.Errorf("未知的目标结构体%q，可选: %s", *schemaName, strings.Join(schemaNames(), ", "))
+		}
+	}
+
+	client, err := newConfigClient(*server, *namespace)
+	if err != nil {
+		return err
+	}
+	result, err := PublishChecked(client, PublishRequest{
+		DataId:      *dataId,
+		Group:       *group,
+		Type:        *configType,
+		Content:     string(content),
+		Schema:      schema,
+		ExpectedMD5: *expectMD5,
+		DryRun:      *dryRun,
+	})
+	if result != nil {
+		printPublishResult(*group, *dataId, result)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Unchanged:
+		fmt.Println("内容与线上配置一致，无需发布")
+	case result.Published:
+		fmt.Println("配置发布成功")
+	default:
+		fmt.Println("dry-run模式，未发布")
+		fmt.Printf("确认无误后执行发布，并带上 --expect-md5 %s 防止覆盖他人在此期间的修改\n", expectedArg(result.OldMD5))
+	}
+	return nil
+}
+
+// runExport export子命令
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	server := fs.String("server", "localhost:8848", "Nacos服务地址")
+	namespace := fs.String("namespace", "", "要导出的命名空间ID，为空表示public")
+	out := fs.String("out", "", "导出位置：目录，或以.tar/.tar.gz/.tgz结尾的归档文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		fs.Usage()
+		return errors.New("--out是必填参数")
+	}
+
+	client, err := newConfigClient(*server, *namespace)
+	if err != nil {
+		return err
+	}
+	archive, err := ExportNamespace(client, *namespace)
+	if err != nil {
+		return err
+	}
+	if err := WriteConfigArchive(archive, *out); err != nil {
+		return fmt.Errorf("写出导出结果失败: %v", err)
+	}
+	for _, item := range archive.Items {
+		fmt.Printf("  %s/%s  类型: %s  md5: %s\n", item.Group, item.DataId, displayConfigType(item.Type), item.MD5)
+	}
+	fmt.Printf("已导出%d份配置到%s\n", len(archive.Items), *out)
+	return nil
+}
+
+// runImport import子命令
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	server := fs.String("server", "localhost:8848", "Nacos服务地址")
+	namespace := fs.String("namespace", "", "导入的目标命名空间ID，为空表示public")
+	in := fs.String("in", "", "export导出的目录或归档文件")
+	conflict := fs.String("conflict", string(ConflictAbort), "目标中已存在内容不同的同名配置时的处理方式: skip, overwrite, abort")
+	dryRun := fs.Bool("dry-run", false, "只展示导入结果，不发布")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		fs.Usage()
+		return errors.New("--in是必填参数")
+	}
+	policy, err := ParseConflictPolicy(*conflict)
+	if err != nil {
+		return err
+	}
+
+	archive, err := ReadConfigArchive(*in)
+	if err != nil {
+		return err
+	}
+	client, err := newConfigClient(*server, *namespace)
+	if err != nil {
+		return err
+	}
+	results, err := ImportNamespace(client, archive, policy, *dryRun)
+	counts := make(map[string]int)
+	for _, result := range results {
+		fmt.Printf("  %-11s %s/%s\n", result.Action, result.Group, result.DataId)
+		counts[result.Action]++
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("新建%d份，覆盖%d份，跳过%d份，未变化%d份\n",
+		counts[ImportCreated], counts[ImportOverwritten], counts[ImportSkipped], counts[ImportUnchanged])
+	if *dryRun {
+		fmt.Println("dry-run模式，未发布")
+	}
+	return nil
+}
+
+// runStandin standin子命令，数据只保存在内存中，进程退出后丢失
+func runStandin(args []string) error {
+	fs := flag.NewFlagSet("standin", flag.ContinueOnError)
+	listen := fs.String("listen", ":8848", "监听地址")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return fmt.Errorf("监听%s失败: %v", *listen, err)
+	}
+	fmt.Printf("Nacos替身服务已启动: http://%s%s\n", listener.Addr(), constant.WEB_CONTEXT)
+	return http.Serve(listener, NewStandinServer())
+}
+
+// printPublishResult 输出MD5和字段级差异
+func printPublishResult(group, dataId string, result *PublishResult) {
+	fmt.Printf("配置[%s/%s]\n", group, dataId)
+	fmt.Printf("  线上MD5: %s\n", displayMD5(result.OldMD5))
+	fmt.Printf("  新的MD5: %s\n", result.NewMD5)
+	if result.Diff.Empty() {
+		return
+	}
+	fmt.Println("字段变更:")
+	for _, change := range result.Diff {
+		fmt.Println("  " + change.String())
+	}
+}
+
+// expectedArg 配置不存在时，--expect-md5需要传空字符串
+func expectedArg(md5sum string) string {
+	if md5sum == "" {
+		return `""`
+	}
+	return md5sum
+}
+
+// displayConfigType 导出清单中类型未知时显示为"(未知)"
+func displayConfigType(configType string) string {
+	if configType == "" {
+		return "(未知)"
+	}
+	return configType
+}
+
+// schemaNames 已注册的目标结构体名称
+func schemaNames() []string {
+	names := make([]string, 0, len(configSchemas))
+	for name := range configSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newConfigClient 根据 host:port 创建配置客户端
+func newConfigClient(server, namespace string) (config_client.IConfigClient, error) {
+	host, portText, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, fmt.Errorf("Nacos服务地址%q格式错误，应为host:port: %v", server, err)
+	}
+	port, err := strconv.ParseUint(portText, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Nacos服务端口%q无效: %v", portText, err)
+	}
+
+	clientConfig := constant.ClientConfig{
+		NamespaceId:         namespace,
+		TimeoutMs:           5000,
+		NotLoadCacheAtStart: true,
+		LogDir:              "./tmp/nacos/log",
+		CacheDir:            "./tmp/nacos/cache",
+		LogLevel:            "warn",
+	}
+	serverConfigs := []constant.ServerConfig{
+		{
+			IpAddr:      host,
+			ContextPath: "/nacos",
+			Port:        port,
+			Scheme:      "http",
+		},
+	}
+	return clients.NewConfigClient(vo.NacosClientParam{
+		ClientConfig:  &clientConfig,
+		ServerConfigs: serverConfigs,
+	})
+}
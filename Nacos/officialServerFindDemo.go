@@ -1,3 +1,7 @@
+//go:build finddemo
+
+// 服务注册与发现示例: go run -tags finddemo .
+
 package main
 
 import (
@@ -8,6 +12,8 @@ import (
 	"github.com/nacos-group/nacos-sdk-go/clients"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/vo"
+
+	"nacos/registry"
 )
 
 func main() {
@@ -21,10 +27,11 @@ func main() {
 
 	// 客户端配置
 	clientConfig := constant.ClientConfig{
-		NamespaceId:         "public", // 默认命名空间
-		TimeoutMs:           5000,
-		NotLoadCacheAtStart: true,
-		LogDir:              "./logs", // 日志目录
+		NamespaceId:          "public", // 默认命名空间
+		TimeoutMs:            5000,
+		NotLoadCacheAtStart:  true,
+		UpdateCacheWhenEmpty: true,     // 实例全部下线时也更新本地缓存，否则会一直返回下线前的实例
+		LogDir:               "./logs", // 日志目录
 	}
 
 	// 创建动态配置客户端的另一种方式 (推荐)
@@ -39,77 +46,89 @@ func main() {
 	}
 	fmt.Println("配置客户端创建成功")
 
+	// 通过Registry接口注册和发现服务，业务代码不直接依赖Nacos SDK
+	var reg registry.Registry = registry.NewNacosRegistry(client)
+
 	// 注册服务
-	success, err := client.RegisterInstance(vo.RegisterInstanceParam{
-		ServiceName: "myservice", // 服务名称（与Nacos控制台显示保持一致）
-		Ip:          "127.0.0.1", // 服务实例的 IP 地址
-		Port:        8080,        // 服务实例的端口号
-		Weight:      1.0,         // 权重（负载均衡的基础）
-		Enable:      true,        // 实例是否启用
-		Ephemeral:   true,        // 是否为临时实例
-		Healthy:     true,        // 实例是否健康
+	instance := registry.ServiceInstance{
+		Service: "myservice", // 服务名称（与Nacos控制台显示保持一致）
+		Ip:      "127.0.0.1", // 服务实例的 IP 地址
+		Port:    8080,        // 服务实例的端口号
+		Weight:  1.0,         // 权重（负载均衡的基础）
+		Group:   "DEFAULT_GROUP",
+		Cluster: "DEFAULT",
+	}.WithMetadata(registry.InstanceMetadata{
+		Version:  "v1",      // 服务版本，灰度发布时按版本路由
+		Zone:     "cn-east", // 可用区，就近访问
+		Protocol: "http",
+		Tags:     []string{"stable"},
 	})
-	if err != nil {
+	// 由生命周期管理注册状态：Nacos重连后自动重新注册，收到SIGTERM时先禁用实例再注销
+	lifecycle := registry.NewServiceLifecycle(reg, instance)
+	if err := lifecycle.Start(); err != nil {
 		log.Fatalf("Error registering service instance: %v", err)
 	}
-	if success {
-		fmt.Println("Service registered successfully!")
-	} else {
-		fmt.Println("Service registration failed!")
-	}
+	fmt.Println("Service registered successfully!")
+	// 关闭时长需要覆盖实例禁用后的等待时间(默认3秒)
+	ctx := SetupGracefulShutdownTimeout(10*time.Second, lifecycle)
 
 	// 注册后添加延迟，确保服务实例完全生效
 	fmt.Println("等待1秒让服务实例完全注册...")
 	time.Sleep(1 * time.Second)
 
-	// 先尝试使用GetService方法验证服务是否存在
-	service, err := client.GetService(vo.GetServiceParam{
-		ServiceName: "myservice",
-		GroupName:   "DEFAULT_GROUP",
-	})
+	// 客户端负载均衡，实例列表由Registry推送
+	balancer, err := registry.NewBalancer(registry.WeightedRoundRobin)
 	if err != nil {
-		fmt.Printf("获取服务信息失败: %v\n", err)
-	} else {
-		fmt.Printf("服务信息: %+v\n", service)
+		log.Fatalf("创建负载均衡器失败: %v", err)
+	}
+	// 同可用区优先，只调用http协议、非灰度的实例
+	balancer.SetZone("cn-east")
+	balancer.SetSelector(registry.MustParseSelector("protocol=http,tags!=canary"))
+	if err := balancer.Follow(ctx, reg, "myservice", "DEFAULT_GROUP"); err != nil {
+		log.Fatalf("订阅服务实例失败: %v", err)
 	}
-	// 通过服务名称查询实例
-	go func() {
-		for {
-			// 获取服务实例列表
-			fmt.Println("正在查询服务实例: myservice")
-			// 尝试使用健康检查为false的查询方式
-			res, err := client.SelectInstances(vo.SelectInstancesParam{
-				ServiceName: "myservice",     // 服务名称（与Nacos控制台显示保持一致）
-				GroupName:   "DEFAULT_GROUP", // 分组名称
-				HealthyOnly: true,            // 只返回健康的实例
-			})
-			// 如果查询失败，尝试使用SelectOneHealthyInstance方法
 
-			if err != nil {
-				// 检查是否是实例列表为空的情况，这种情况在没有服务注册时是正常的
-				if err.Error() == "instance list is empty!" {
-					fmt.Println("未发现服务实例，请等待服务注册...")
-				} else {
-					log.Printf("查询服务实例错误: %v", err)
-				}
-				continue // 继续下一次查询
+	// 订阅服务实例的变化，由Nacos推送，不再轮询
+	watcher := registry.NewInstanceWatcher(reg, "myservice", "DEFAULT_GROUP")
+	deltas, err := watcher.Watch(ctx)
+	if err != nil {
+		log.Fatalf("订阅服务实例失败: %v", err)
+	}
+	go func() {
+		for delta := range deltas {
+			// 打印实例变化
+			for _, change := range delta.Changes {
+				fmt.Printf("实例变化: %s\n", change)
 			}
 
-			// 打印服务实例
+			// 打印服务实例，只显示健康的实例
 			fmt.Println("服务实例列表:")
+			res := registry.HealthyInstances(delta.Instances)
 			if len(res) == 0 {
 				fmt.Println("  [暂无可用实例]")
 			} else {
 				for _, instance := range res {
-					fmt.Printf("  IP: %s, Port: %d\n", instance.Ip, instance.Port, instance.Weight)
+					meta := instance.Meta()
+					fmt.Printf("  IP: %s, Port: %d, Weight: %g, Version: %s, Zone: %s, Tags: %v\n",
+						instance.Ip, instance.Port, instance.Weight, meta.Version, meta.Zone, meta.Tags)
 				}
 			}
+		}
+	}()
 
-			// 每隔 3 秒查询一次
-			time.Sleep(3 * time.Second)
+	// 模拟业务调用：每隔 3 秒按权重选择一个实例，调用结束后反馈结果
+	go func() {
+		for range time.Tick(3 * time.Second) {
+			endpoint, err := balancer.Pick("")
+			if err != nil {
+				fmt.Printf("选择实例失败: %v\n", err)
+				continue
+			}
+			fmt.Printf("本次调用的实例: %s\n", endpoint.Address())
+			endpoint.Done(nil)
 		}
 	}()
 
-	// 模拟服务运行，保持客户端活跃
-	select {}
+	// 模拟服务运行，直到收到退出信号并完成注销
+	<-ctx.Done()
 }
@@ -1,6 +1,13 @@
+//go:build !nacosctl && !finddemo
+
+// 配置热更新示例，默认构建的入口: go run . [-rollback]
+// 服务发现示例和nacosctl命令行工具分别通过 -tags finddemo、-tags nacosctl 构建
+
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"time"
@@ -12,23 +19,10 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-// 定义解析yaml文件装载的结构体
-// 定义一个结构体用于存储YAML配置
-type ConfigData struct {
-	AppName    string   `yaml:"appName"`
-	ServerPort int      `yaml:"serverPort"`
-	Database   Database `yaml:"database"`
-	Features   []string `yaml:"features"`
-}
-
-// 数据库配置结构体
-type Database struct {
-	Url      string `yaml:"url"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-}
-
 func main() {
+	rollback := flag.Bool("rollback", false, "启动后把配置回滚到上一个版本(会重新发布Nacos上的配置)")
+	flag.Parse()
+
 	fmt.Println("Nacos非阻塞配置热更新示例")
 	//创建客户端配置
 	clientConfig := constant.ClientConfig{
@@ -72,7 +66,29 @@ func main() {
 
 	// 初始读取配置
 	read(configClient)
-	rollbackConfig()
+
+	// 通过配置管理器记录版本历史，支持对比和回滚
+	configManager := NewConfigManager[ConfigData](configClient, "dataId", "group", "yaml")
+	// 配置中的${secret:db/password}从环境变量SECRET_DB_PASSWORD读取，
+	// 部署在Kubernetes上时可以改用NewFileSecretProvider读取Secret挂载的目录
+	configManager.AddSecretProvider(NewEnvSecretProvider("SECRET"))
+	// 每次配置生效或被拒绝都写一条审计事件，同时统计成指标(可以挂到/metrics)
+	configManager.SetNamespace(clientConfig.NamespaceId)
+	if auditSink, err := NewJSONLinesAuditSink("./tmp/nacos/audit.jsonl"); err != nil {
+		fmt.Println("打开审计日志失败:", err.Error())
+	} else {
+		configManager.AddAuditSink(auditSink)
+	}
+	configManager.AddAuditSink(NewConfigMetrics())
+	if err := configManager.Start(context.Background()); err != nil {
+		fmt.Println("配置管理器启动失败:", err.Error())
+	} else {
+		featureFlagDemo(configManager)
+		// 回滚会改写Nacos上的配置，只在显式传入-rollback时执行
+		if *rollback {
+			rollbackConfig(configManager)
+		}
+	}
 
 	// 在独立的goroutine中启动配置监听，不阻塞主程序运行
 	go ListenConfig(configClient)
@@ -103,7 +119,7 @@ func read(configClient config_client.IConfigClient) {
 		fmt.Println("获取配置出错:", err.Error())
 		return
 	}
-	fmt.Println("原始配置内容:", content)
+	fmt.Println("原始配置内容:", RedactContent(content))
 
 	// 将配置保存到config.yaml文件
 	err = os.WriteFile("config.yaml", []byte(content), 0644)
@@ -134,7 +150,7 @@ func read(configClient config_client.IConfigClient) {
 	fmt.Printf("  服务器端口: %d\n", config.ServerPort)
 	fmt.Printf("  数据库URL: %s\n", config.Database.Url)
 	fmt.Printf("  数据库用户名: %s\n", config.Database.Username)
-	fmt.Printf("  数据库密码: %s\n", config.Database.Password)
+	fmt.Printf("  数据库密码: %s\n", redactedValue) // 不显示密码明文
 	fmt.Printf("  功能列表: %v\n", config.Features)
 
 	// 现在可以使用这个config变量在程序的其他地方
@@ -148,29 +164,41 @@ serverPort: 8080
 database:
   url: jdbc:mysql://localhost:3306/mydb
   username: admin
-  password: password123
+  password: ${secret:db/password}
 features:
   - login
   - dashboard
   - reporting
+flags:
+  new-checkout:
+    enabled: true
+    percentage: 20
+    rules:
+      - tenants: [acme]
 `
 
+	// 发布前按ConfigData校验内容，并确认线上配置没有被其他人修改过
+	// 日常发布建议使用命令行: nacosctl publish --data-id dataId --group group --file config.yaml --dry-run
 	fmt.Println("准备发布YAML格式配置...")
-	success, err := configClient.PublishConfig(vo.ConfigParam{
+	result, err := PublishChecked(configClient, PublishRequest{
 		DataId:  "dataId",
 		Group:   "group",
 		Content: yamlContent,
-		Type:    "yaml"}) // 发布YAML格式的配置
+		Type:    "yaml", // 发布YAML格式的配置
+		Schema:  SchemaOf[ConfigData](),
+	})
 	if err != nil {
 		fmt.Println("发布配置出错:", err.Error())
 		return
 	}
-	if success {
-		fmt.Println("YAML格式配置发布成功")
-		fmt.Println("发布的配置内容:")
-		fmt.Println(yamlContent)
-	} else {
-		fmt.Println("发布配置失败")
+	if result.Unchanged {
+		fmt.Println("配置内容没有变化，无需发布")
+		return
+	}
+	fmt.Println("YAML格式配置发布成功")
+	fmt.Println("字段变更:")
+	for _, change := range result.Diff {
+		fmt.Println("  " + change.String())
 	}
 }
 
@@ -189,7 +217,8 @@ func ListenConfig(configClient config_client.IConfigClient) {
 			// 重新读取配置并更新文件
 			read(configClient)
 			fmt.Println("配置热更新完成")
-			fmt.Println("==========================================\n")
+			fmt.Println("==========================================")
+			fmt.Println()
 		},
 	})
 
@@ -198,33 +227,62 @@ func ListenConfig(configClient config_client.IConfigClient) {
 	select {}
 }
 
+// 功能开关demo
+// features中的功能对所有人开启，flags中的开关支持按比例灰度和按用户/租户定向，
+// 在Nacos上修改后不需要重启服务即可生效
+func featureFlagDemo(configManager *ConfigManager[ConfigData]) {
+	flags := NewFeatureFlags(configManager, (*ConfigData).EffectiveFlags)
+	flags.OnChange("new-checkout", func(change FeatureChange) {
+		fmt.Printf("\n功能开关[%s]变更: %v -> %v\n", change.Name, change.Old, change.New)
+	})
+
+	fmt.Println("\n当前的功能开关:")
+	for _, name := range flags.Names() {
+		flag, _ := flags.Flag(name)
+		fmt.Printf("  %s: %v\n", name, flag)
+	}
+	fmt.Println("reporting对所有人开启:", flags.IsEnabled("reporting"))
+	fmt.Println("new-checkout对acme租户开启:", flags.IsEnabledFor("new-checkout", FeatureContext{TenantID: "acme"}))
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		if flags.IsEnabledFor("new-checkout", FeatureContext{UserID: fmt.Sprintf("user-%d", i)}) {
+			enabled++
+		}
+	}
+	fmt.Printf("new-checkout在1000个用户中开启了%d个\n", enabled)
+}
+
 // 版本管理demo
-func rollbackConfig() {
-	//进行版本管理可以进行如下操作：
-	//1.通过给dataId添加版本号来实现配置的版本管理
-	//2.通过使用searchConfig函数来搜索配置版本，找到需要回滚的版本号
-	//3.调用publishConfig函数来发布回滚后的配置
-
-	//searcgConfig使用实例如下：
-	// searchResult, err := client.SearchConfig(vo.SearchConfigParam{
-	// 	Search:   "blur",    // 搜索关键字
-	// 	DataId:   "",        // 不指定数据 ID，表示搜索所有配置
-	// 	Group:    "",        // 不指定配置分组，表示搜索所有分组
-	// 	PageNo:   1,         // 页码，默认从第 1 页开始
-	// 	PageSize: 10,        // 每页返回 10 个配置项
-	// })
-	// if err != nil {
-	// 	return fmt.Errorf("failed to search config: %v", err)
-	// }
-	// 输出搜索结果
-	// fmt.Println("Search result:")
-	// fmt.Printf("Total count: %d\n", searchResult.Count)
-	// for _, config := range searchResult.Configs {
-	// 	fmt.Printf("DataId: %s, Group: %s, Content: %s\n", config.DataId, config.Group, config.Content)
-	// }
-	//返回的结果：
-	// Search result:
-	// Total count: 2
-	// DataId: app-config, Group: DEFAULT_GROUP, Content: blur effect
-	// DataId: image-config, Group: DEFAULT_GROUP, Content: background blur
+// ConfigManager会在本地保留最近生效过的配置版本(包含MD5、生效时间和来源)，
+// 可以对比任意两个版本，并通过PublishConfig把配置一键回滚到历史版本
+func rollbackConfig(configManager *ConfigManager[ConfigData]) {
+	versions := configManager.ListVersions()
+	fmt.Println("\n本地保留的配置版本:")
+	for _, v := range versions {
+		fmt.Printf("  版本%d  md5: %s  来源: %s  生效时间: %s\n", v.Version, v.MD5, v.Source, v.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	if len(versions) < 2 {
+		fmt.Println("历史版本不足两个，无需回滚")
+		return
+	}
+
+	previous, current := versions[len(versions)-2], versions[len(versions)-1]
+	diff, err := configManager.Diff(previous.Version, current.Version)
+	if err != nil {
+		fmt.Println("对比版本出错:", err.Error())
+		return
+	}
+	fmt.Printf("版本%d -> 版本%d 的变更:\n", previous.Version, current.Version)
+	for _, change := range diff {
+		fmt.Println("  " + RedactSecrets(change.String()))
+	}
+
+	// 回滚会重新发布历史版本的内容，并等待监听器确认新配置已经生效
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := configManager.Rollback(ctx, previous.Version); err != nil {
+		fmt.Println("回滚配置出错:", err.Error())
+		return
+	}
+	fmt.Printf("已回滚到版本%d\n", previous.Version)
 }
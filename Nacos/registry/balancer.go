@@ -0,0 +1,323 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 客户端负载均衡：由Registry.Watch(或List)的结果驱动，每次调用前Pick一个实例，
+// 调用结束后通过Endpoint.Done反馈结果，连续失败的实例会被临时摘除
+// 用法:
+//   balancer, _ := registry.NewBalancer(registry.WeightedRoundRobin)
+//   balancer.Follow(ctx, reg, "myservice", "DEFAULT_GROUP")
+//   endpoint, err := balancer.Pick("")
+//   err = call(endpoint.Address()); endpoint.Done(err)
+
+// BalanceStrategy 负载均衡策略
+type BalanceStrategy string
+
+const (
+	WeightedRoundRobin BalanceStrategy = "weighted_round_robin" //平滑加权轮询，按权重比例分配请求
+	LeastConnections   BalanceStrategy = "least_connections"    //选择进行中请求数/权重最小的实例
+	ConsistentHash     BalanceStrategy = "consistent_hash"      //按Pick的key做一致性哈希，实例变化时只影响少量key
+)
+
+const (
+	defaultEjectFailures   = 3                //连续失败多少次后摘除实例
+	defaultEjectDuration   = 30 * time.Second //实例被摘除的时长
+	consistentHashReplicas = 100              //权重为1的实例在哈希环上的虚拟节点数
+)
+
+// ErrNoEndpoint 没有可用的服务实例
+var ErrNoEndpoint = errors.New("没有可用的服务实例")
+
+// Balancer 客户端负载均衡器，并发安全
+type Balancer struct {
+	mutex         sync.Mutex
+	strategy      BalanceStrategy
+	zone          string
+	selector      Selector
+	instances     []ServiceInstance //最近一次Update的完整列表，修改选择器时重新筛选
+	ejectFailures int
+	ejectDuration time.Duration
+	nodes         []*balancerNode          //可以接收流量的实例，按实例ID排序
+	byID          map[string]*balancerNode //实例ID -> 节点，实例列表更新时保留连接数和失败状态
+	ring          []ringPoint              //一致性哈希环，按hash排序
+	offset        int                      //最小连接数相同时轮流选择的起点
+}
+
+// balancerNode 实例及其负载均衡状态
+type balancerNode struct {
+	instance      ServiceInstance
+	currentWeight float64   //平滑加权轮询的当前权重
+	inflight      int       //进行中的请求数
+	failures      int       //连续失败次数
+	ejectedUntil  time.Time //摘除截止时间
+}
+
+// ringPoint 一致性哈希环上的虚拟节点
+type ringPoint struct {
+	hash uint32
+	node *balancerNode
+}
+
+// Endpoint 一次Pick的结果，调用结束后必须调用Done
+type Endpoint struct {
+	Instance ServiceInstance
+	balancer *Balancer
+	node     *balancerNode
+	done     atomic.Bool
+}
+
+// NewBalancer 创建负载均衡器
+func NewBalancer(strategy BalanceStrategy) (*Balancer, error) {
+	switch strategy {
+	case WeightedRoundRobin, LeastConnections, ConsistentHash:
+	default:
+		return nil, fmt.Errorf("不支持的负载均衡策略: %s", strategy)
+	}
+	return &Balancer{
+		strategy:      strategy,
+		ejectFailures: defaultEjectFailures,
+		ejectDuration: defaultEjectDuration,
+		byID:          make(map[string]*balancerNode),
+	}, nil
+}
+
+// SetZone 开启同可用区优先：本可用区(实例元数据zone)有可用实例时只在本可用区内选择，否则使用全部实例
+func (b *Balancer) SetZone(zone string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.zone = zone
+}
+
+// SetSelector 只在满足标签选择器的实例中选择，例如灰度流量使用"version=v2"
+func (b *Balancer) SetSelector(selector Selector) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.selector = selector
+	b.rebuild()
+}
+
+// SetEjection 设置连续失败多少次后摘除实例，以及摘除的时长；failures为0时不摘除
+func (b *Balancer) SetEjection(failures int, duration time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.ejectFailures = failures
+	b.ejectDuration = duration
+}
+
+// Update 更新实例列表，只保留健康、已启用、权重大于0且满足标签选择器的实例；
+// 已存在的实例保留进行中的请求数和摘除状态
+func (b *Balancer) Update(instances []ServiceInstance) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.instances = instances
+	b.rebuild()
+}
+
+// rebuild 按最近的实例列表和选择器重建可选实例，调用方需持有mutex
+func (b *Balancer) rebuild() {
+	healthy := b.selector.Filter(HealthyInstances(b.instances))
+	byID := make(map[string]*balancerNode, len(healthy))
+	nodes := make([]*balancerNode, 0, len(healthy))
+	for _, instance := range healthy {
+		node, ok := b.byID[instance.ID]
+		if !ok {
+			node = &balancerNode{}
+		}
+		node.instance = instance
+		byID[instance.ID] = node
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].instance.ID < nodes[j].instance.ID })
+	b.byID = byID
+	b.nodes = nodes
+	if b.strategy == ConsistentHash {
+		b.ring = buildHashRing(nodes)
+	}
+}
+
+// Follow 订阅服务的实例变化并自动Update，ctx结束后停止
+func (b *Balancer) Follow(ctx context.Context, registry Registry, service, group string) error {
+	updates, err := registry.Watch(ctx, service, group)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for instances := range updates {
+			b.Update(instances)
+		}
+	}()
+	return nil
+}
+
+// Pick 选择一个实例，key只在一致性哈希策略下使用(例如用户ID)
+func (b *Balancer) Pick(key string) (*Endpoint, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	candidates := b.candidates()
+	if len(candidates) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	var node *balancerNode
+	switch b.strategy {
+	case LeastConnections:
+		node = b.pickLeastConnections(candidates)
+	case ConsistentHash:
+		node = b.pickConsistentHash(candidates, key)
+	default:
+		node = pickWeightedRoundRobin(candidates)
+	}
+	node.inflight++
+	return &Endpoint{Instance: node.instance, balancer: b, node: node}, nil
+}
+
+// candidates 可选的实例：排除被摘除的实例，开启同可用区优先时优先本可用区；
+// 全部实例都被摘除时忽略摘除状态，避免因为误判导致完全不可用。调用方需持有mutex
+func (b *Balancer) candidates() []*balancerNode {
+	now := time.Now()
+	available := make([]*balancerNode, 0, len(b.nodes))
+	for _, node := range b.nodes {
+		if !now.Before(node.ejectedUntil) {
+			available = append(available, node)
+		}
+	}
+	if len(available) == 0 {
+		available = b.nodes
+	}
+	if b.zone == "" {
+		return available
+	}
+
+	var local []*balancerNode
+	for _, node := range available {
+		if node.instance.Metadata[MetadataZone] == b.zone {
+			local = append(local, node)
+		}
+	}
+	if len(local) == 0 {
+		return available
+	}
+	return local
+}
+
+// pickWeightedRoundRobin 平滑加权轮询(与nginx相同的算法)，权重5:1:1时选择顺序为a a b a c a a
+func pickWeightedRoundRobin(candidates []*balancerNode) *balancerNode {
+	var best *balancerNode
+	total := 0.0
+	for _, node := range candidates {
+		node.currentWeight += node.instance.Weight
+		total += node.instance.Weight
+		if best == nil || node.currentWeight > best.currentWeight {
+			best = node
+		}
+	}
+	best.currentWeight -= total
+	return best
+}
+
+// pickLeastConnections 选择进行中请求数/权重最小的实例，相同时轮流选择。调用方需持有mutex
+func (b *Balancer) pickLeastConnections(candidates []*balancerNode) *balancerNode {
+	b.offset++
+	var best *balancerNode
+	bestLoad := math.MaxFloat64
+	for i := range candidates {
+		node := candidates[(b.offset+i)%len(candidates)]
+		load := float64(node.inflight+1) / node.instance.Weight
+		if load < bestLoad {
+			best, bestLoad = node, load
+		}
+	}
+	return best
+}
+
+// pickConsistentHash 从key的哈希值沿哈希环顺时针找到第一个可选的实例，
+// 被摘除或不在本可用区的实例会被跳过，其余key的映射不受影响
+func (b *Balancer) pickConsistentHash(candidates []*balancerNode, key string) *balancerNode {
+	allowed := make(map[*balancerNode]bool, len(candidates))
+	for _, node := range candidates {
+		allowed[node] = true
+	}
+	hash := hashKey(key)
+	start := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= hash })
+	for i := range b.ring {
+		point := b.ring[(start+i)%len(b.ring)]
+		if allowed[point.node] {
+			return point.node
+		}
+	}
+	return candidates[0]
+}
+
+// buildHashRing 按权重为每个实例生成虚拟节点
+func buildHashRing(nodes []*balancerNode) []ringPoint {
+	var ring []ringPoint
+	for _, node := range nodes {
+		replicas := max(1, int(math.Round(node.instance.Weight*consistentHashReplicas)))
+		for i := 0; i < replicas; i++ {
+			ring = append(ring, ringPoint{hash: hashKey(node.instance.ID + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// hashKey 一致性哈希使用的哈希函数：fnv对相近的字符串(例如user1、user2)分布不够均匀，
+// 再用murmur3的fmix32打散
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+// Address 返回 ip:port
+func (e *Endpoint) Address() string {
+	return e.Instance.Address()
+}
+
+// Done 反馈调用结果，err不为空表示调用失败；重复调用只有第一次生效
+func (e *Endpoint) Done(err error) {
+	if !e.done.CompareAndSwap(false, true) {
+		return
+	}
+	e.balancer.done(e.node, err)
+}
+
+// done 更新实例的进行中请求数和失败状态
+func (b *Balancer) done(node *balancerNode, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	node.inflight--
+	if err == nil {
+		node.failures = 0
+		return
+	}
+	node.failures++
+	if b.ejectFailures > 0 && node.failures >= b.ejectFailures {
+		node.failures = 0
+		node.ejectedUntil = time.Now().Add(b.ejectDuration)
+		logf("实例%s连续失败%d次，摘除%v: %v\n", node.instance.Address(), b.ejectFailures, b.ejectDuration, err)
+	}
+}
@@ -0,0 +1,275 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// node 生成可以接收流量的实例，用名称代替IP便于比较选择顺序
+func node(name string, weight float64, zone string) ServiceInstance {
+	instance, _ := normalizeInstance(ServiceInstance{Service: "myservice", Ip: name, Port: 8080})
+	instance.Weight = weight
+	instance.Healthy = true
+	instance.Enabled = true
+	if zone != "" {
+		instance.Metadata = map[string]string{MetadataZone: zone}
+	}
+	return instance
+}
+
+// newTestBalancer 创建负载均衡器并更新实例列表
+func newTestBalancer(t *testing.T, strategy BalanceStrategy, instances ...ServiceInstance) *Balancer {
+	t.Helper()
+	b, err := NewBalancer(strategy)
+	if err != nil {
+		t.Fatalf("创建负载均衡器失败: %v", err)
+	}
+	b.Update(instances)
+	return b
+}
+
+// pickSequence 连续选择n次，每次调用立即成功结束，返回选中实例的名称序列
+func pickSequence(t *testing.T, b *Balancer, n int) string {
+	t.Helper()
+	var names []string
+	for i := 0; i < n; i++ {
+		endpoint, err := b.Pick("")
+		if err != nil {
+			t.Fatalf("第%d次选择失败: %v", i+1, err)
+		}
+		names = append(names, endpoint.Instance.Ip)
+		endpoint.Done(nil)
+	}
+	return strings.Join(names, " ")
+}
+
+// pickKey 按key选择一次并成功结束，返回选中实例的名称
+func pickKey(t *testing.T, b *Balancer, key string) string {
+	t.Helper()
+	endpoint, err := b.Pick(key)
+	if err != nil {
+		t.Fatalf("按%s选择失败: %v", key, err)
+	}
+	endpoint.Done(nil)
+	return endpoint.Instance.Ip
+}
+
+// failInstance 对指定实例的n次调用反馈失败，选中其他实例时反馈成功
+func failInstance(t *testing.T, b *Balancer, name string, n int) {
+	t.Helper()
+	for i := 0; n > 0; i++ {
+		if i == 100 {
+			t.Fatalf("一直没有选中实例%s", name)
+		}
+		endpoint, err := b.Pick("")
+		if err != nil {
+			t.Fatalf("选择失败: %v", err)
+		}
+		if endpoint.Instance.Ip != name {
+			endpoint.Done(nil)
+			continue
+		}
+		endpoint.Done(errors.New("connection refused"))
+		n--
+	}
+}
+
+// pickedNames 连续选择n次，返回选中过的实例名称集合
+func pickedNames(t *testing.T, b *Balancer, n int) map[string]bool {
+	t.Helper()
+	names := make(map[string]bool)
+	for _, name := range strings.Fields(pickSequence(t, b, n)) {
+		names[name] = true
+	}
+	return names
+}
+
+func TestNewBalancerRejectsUnknownStrategy(t *testing.T) {
+	if _, err := NewBalancer("random"); err == nil {
+		t.Error("不支持的策略应该返回错误")
+	}
+	b := newTestBalancer(t, WeightedRoundRobin)
+	if _, err := b.Pick(""); !errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("没有实例时返回%v，期望ErrNoEndpoint", err)
+	}
+}
+
+func TestBalancerWeightedRoundRobin(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances []ServiceInstance
+		want      string
+	}{
+		{"平滑加权5:1:1", []ServiceInstance{node("a", 5, ""), node("b", 1, ""), node("c", 1, "")}, "a a b a c a a a a b a c a a"},
+		{"权重相同时轮流选择", []ServiceInstance{node("a", 1, ""), node("b", 1, ""), node("c", 1, "")}, "a b c a b c"},
+		{"权重2:1", []ServiceInstance{node("a", 2, ""), node("b", 1, "")}, "a b a a b a"},
+		{"只有一个实例", []ServiceInstance{node("a", 3, "")}, "a a a"},
+		{"不健康和权重为0的实例被过滤", []ServiceInstance{node("a", 1, ""), {ID: "b", Service: "myservice", Ip: "b", Port: 8080, Weight: 1}, node("c", 0, "")}, "a a"},
+	}
+	for _, tt := range tests {
+		// 按实例ID排序，与传入的顺序无关
+		reversed := make([]ServiceInstance, len(tt.instances))
+		for i, instance := range tt.instances {
+			reversed[len(tt.instances)-1-i] = instance
+		}
+		b := newTestBalancer(t, WeightedRoundRobin, reversed...)
+		if got := pickSequence(t, b, len(strings.Fields(tt.want))); got != tt.want {
+			t.Errorf("%s: 选择顺序为%s，期望%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBalancerLeastConnections(t *testing.T) {
+	b := newTestBalancer(t, LeastConnections, node("a", 1, ""), node("b", 1, ""), node("c", 1, ""))
+
+	// 进行中请求数相同时轮流选择，连续三次覆盖全部实例
+	for i := 0; i < 3; i++ {
+		if names := pickedNames(t, b, 3); len(names) != 3 {
+			t.Fatalf("第%d轮选择了%v，期望轮流选择全部实例", i+1, names)
+		}
+	}
+
+	// 选择进行中请求数最少的实例
+	first, _ := b.Pick("")
+	second, _ := b.Pick("")
+	third, _ := b.Pick("")
+	if first.Instance.Ip == second.Instance.Ip || third.Instance.Ip == first.Instance.Ip || third.Instance.Ip == second.Instance.Ip {
+		t.Errorf("三个请求进行中时选择了%s %s %s", first.Instance.Ip, second.Instance.Ip, third.Instance.Ip)
+	}
+	second.Done(nil)
+	second.Done(nil) // 重复Done只生效一次
+	if got := pickSequence(t, b, 2); got != second.Instance.Ip+" "+second.Instance.Ip {
+		t.Errorf("只有%s空闲时选择了%s", second.Instance.Ip, got)
+	}
+	first.Done(nil)
+	third.Done(nil)
+
+	// 按进行中请求数/权重比较
+	b = newTestBalancer(t, LeastConnections, node("a", 3, ""), node("b", 1, ""))
+	first, _ = b.Pick("")
+	second, _ = b.Pick("")
+	if first.Instance.Ip != "a" || second.Instance.Ip != "a" {
+		t.Errorf("权重3:1时前两个请求选择了%s %s，期望都是a", first.Instance.Ip, second.Instance.Ip)
+	}
+}
+
+func TestBalancerConsistentHash(t *testing.T) {
+	a, bb, c := node("a", 1, ""), node("b", 1, ""), node("c", 1, "")
+	b := newTestBalancer(t, ConsistentHash, a, bb, c)
+
+	before := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		before[key] = pickKey(t, b, key)
+		counts[before[key]]++
+		if again := pickKey(t, b, key); again != before[key] {
+			t.Fatalf("%s两次选择的实例不同: %s %s", key, before[key], again)
+		}
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if counts[name] < 200 {
+			t.Errorf("1000个key中只有%d个落在%s上: %v", counts[name], name, counts)
+		}
+	}
+
+	// 移除一个实例，只有原来落在该实例上的key会迁移
+	b.Update([]ServiceInstance{a, c})
+	for key, name := range before {
+		got := pickKey(t, b, key)
+		if name == "b" && got == "b" {
+			t.Fatalf("%s仍然落在已移除的实例上", key)
+		}
+		if name != "b" && got != name {
+			t.Errorf("移除b后%s从%s迁移到了%s", key, name, got)
+		}
+	}
+
+	// 实例恢复后映射与之前一致
+	b.Update([]ServiceInstance{c, bb, a})
+	for key, name := range before {
+		if got := pickKey(t, b, key); got != name {
+			t.Errorf("恢复b后%s落在%s，期望%s", key, got, name)
+		}
+	}
+}
+
+func TestBalancerZoneFallback(t *testing.T) {
+	b := newTestBalancer(t, WeightedRoundRobin, node("a", 1, "cn-east"), node("b", 1, "cn-west"), node("c", 1, "cn-west"))
+
+	b.SetZone("cn-east")
+	if got := pickSequence(t, b, 3); got != "a a a" {
+		t.Errorf("同可用区优先时选择了%s", got)
+	}
+
+	// 本可用区没有实例时使用全部实例
+	b.SetZone("cn-north")
+	if names := pickedNames(t, b, 3); len(names) != 3 {
+		t.Errorf("本可用区没有实例时选择了%v", names)
+	}
+
+	// 本可用区的实例都被摘除时使用其他可用区
+	b.SetZone("cn-east")
+	b.SetEjection(1, time.Minute)
+	failInstance(t, b, "a", 1)
+	if names := pickedNames(t, b, 4); names["a"] || len(names) != 2 {
+		t.Errorf("本可用区的实例被摘除后选择了%v", names)
+	}
+}
+
+func TestBalancerEjection(t *testing.T) {
+	b := newTestBalancer(t, WeightedRoundRobin, node("a", 1, ""), node("b", 1, ""), node("c", 1, ""))
+	b.SetEjection(3, time.Minute)
+
+	// 成功的调用清零连续失败次数
+	failInstance(t, b, "a", 2)
+	if names := pickedNames(t, b, 3); !names["a"] {
+		t.Fatalf("a失败2次后不应被摘除，选择了%v", names)
+	}
+	failInstance(t, b, "a", 2)
+	if names := pickedNames(t, b, 3); !names["a"] {
+		t.Fatalf("a中间有成功的调用，不应被摘除，选择了%v", names)
+	}
+
+	// 连续失败达到阈值后摘除
+	failInstance(t, b, "a", 3)
+	if names := pickedNames(t, b, 6); names["a"] || len(names) != 2 {
+		t.Fatalf("a连续失败3次后选择了%v", names)
+	}
+
+	// 实例列表更新时保留摘除状态
+	b.Update([]ServiceInstance{node("c", 1, ""), node("b", 1, ""), node("a", 1, "")})
+	if names := pickedNames(t, b, 6); names["a"] {
+		t.Fatalf("更新实例列表后a的摘除状态丢失，选择了%v", names)
+	}
+
+	// 全部实例都被摘除时忽略摘除状态
+	failInstance(t, b, "b", 3)
+	failInstance(t, b, "c", 3)
+	if names := pickedNames(t, b, 6); len(names) != 3 {
+		t.Errorf("全部实例都被摘除时选择了%v，期望使用全部实例", names)
+	}
+}
+
+func TestBalancerEjectionExpires(t *testing.T) {
+	b := newTestBalancer(t, WeightedRoundRobin, node("a", 1, ""), node("b", 1, ""))
+	b.SetEjection(1, 50*time.Millisecond)
+	failInstance(t, b, "a", 1)
+	if got := pickSequence(t, b, 2); got != "b b" {
+		t.Fatalf("a被摘除后选择了%s", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if names := pickedNames(t, b, 2); !names["a"] {
+		t.Errorf("摘除时间到期后a没有恢复，选择了%v", names)
+	}
+
+	// failures为0时不摘除
+	b.SetEjection(0, time.Minute)
+	failInstance(t, b, "a", 5)
+	if names := pickedNames(t, b, 2); !names["a"] {
+		t.Errorf("关闭摘除后a仍被摘除，选择了%v", names)
+	}
+}
@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 服务自注册的生命周期：Start时注册实例；运行期间订阅自身服务，实例从注册中心消失时
+// (例如Nacos重启、网络断开期间实例过期)自动重新注册；Stop时先禁用实例，
+// 等消费者刷新实例列表、不再发来新请求后再注销
+// 用法:
+//   lifecycle := registry.NewServiceLifecycle(reg, instance)
+//   if err := lifecycle.Start(); err != nil { ... }
+//   ctx := SetupGracefulShutdownTimeout(10*time.Second, lifecycle, configManager) // 放在最前面，先下线实例再关闭其他组件
+// 关闭时长由全部组件共用，应大于drainDelay加上其他组件的关闭时间，否则排在后面的组件来不及关闭
+
+const (
+	defaultDrainDelay    = 3 * time.Second //禁用实例后等待消费者摘除的时间
+	defaultRegisterRetry = 5 * time.Second //重新注册失败后的重试间隔
+)
+
+// ServiceLifecycle 服务自注册的生命周期，实现主程序的Stoppable接口
+type ServiceLifecycle struct {
+	lifecycleMutex sync.Mutex //保证Start和Stop串行执行
+	mutex          sync.Mutex
+	registry       Registry
+	instance       ServiceInstance
+	drainDelay     time.Duration
+	retryInterval  time.Duration
+	running        bool
+	cancel         context.CancelFunc
+	done           chan struct{}
+}
+
+// NewServiceLifecycle 创建自注册的生命周期
+func NewServiceLifecycle(registry Registry, instance ServiceInstance) *ServiceLifecycle {
+	return &ServiceLifecycle{
+		registry:      registry,
+		instance:      instance,
+		drainDelay:    defaultDrainDelay,
+		retryInterval: defaultRegisterRetry,
+	}
+}
+
+// SetDrainDelay 设置禁用实例后等待消费者摘除的时间，应不小于消费者刷新实例列表的间隔；
+// Stop的ctx先到期时提前注销
+func (l *ServiceLifecycle) SetDrainDelay(delay time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.drainDelay = delay
+}
+
+// SetRetryInterval 设置重新注册失败后的重试间隔
+func (l *ServiceLifecycle) SetRetryInterval(interval time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.retryInterval = interval
+}
+
+// Start 注册实例并开始守护注册状态，重复调用是幂等的；Stop之后可以再次Start
+func (l *ServiceLifecycle) Start() error {
+	l.lifecycleMutex.Lock()
+	defer l.lifecycleMutex.Unlock()
+
+	if l.running {
+		return nil
+	}
+	instance, err := normalizeInstance(l.instance)
+	if err != nil {
+		return err
+	}
+	if err := l.registry.Register(instance); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deltas, err := NewInstanceWatcher(l.registry, instance.Service, instance.Group).Watch(ctx)
+	if err != nil {
+		cancel()
+		if deregisterErr := l.registry.Deregister(instance); deregisterErr != nil {
+			logf("注销实例失败: %v\n", deregisterErr)
+		}
+		return fmt.Errorf("订阅服务[%s/%s]失败: %v", instance.Group, instance.Service, err)
+	}
+
+	l.mutex.Lock()
+	retryInterval := l.retryInterval
+	l.mutex.Unlock()
+
+	l.instance = instance
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	l.running = true
+	go l.keepRegistered(ctx, instance, deltas, retryInterval)
+
+	logf("实例已注册: %s/%s %s\n", instance.Group, instance.Service, instance.Address())
+	return nil
+}
+
+// Stop 实现主程序的Stoppable接口：禁用实例，等待消费者摘除后注销
+func (l *ServiceLifecycle) Stop(ctx context.Context) error {
+	l.lifecycleMutex.Lock()
+	defer l.lifecycleMutex.Unlock()
+
+	if !l.running {
+		return nil
+	}
+	l.running = false
+
+	// 先停止守护，避免下线过程中又被重新注册
+	l.cancel()
+	<-l.done
+
+	l.mutex.Lock()
+	drainDelay := l.drainDelay
+	l.mutex.Unlock()
+
+	if err := l.registry.SetEnabled(l.instance, false); err != nil {
+		logf("禁用实例失败，直接注销: %v\n", err)
+	} else if drainDelay > 0 {
+		logf("实例已禁用，等待%v让消费者摘除: %s\n", drainDelay, l.instance.Address())
+		timer := time.NewTimer(drainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	// ctx到期时也要注销，否则消费者要等到实例心跳过期才会摘除
+	if err := l.registry.Deregister(l.instance); err != nil {
+		return err
+	}
+	logf("实例已注销: %s\n", l.instance.Address())
+	return nil
+}
+
+// keepRegistered 实例从注册中心消失时重新注册，失败后按retryInterval重试
+func (l *ServiceLifecycle) keepRegistered(ctx context.Context, instance ServiceInstance, deltas <-chan InstanceDelta, retryInterval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	// 重复注册是幂等的，本地缓存过期导致误判时最多多注册一次
+	missing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delta, ok := <-deltas:
+			if !ok {
+				return
+			}
+			missing = !containsInstance(delta.Instances, instance.ID)
+		case <-ticker.C:
+		}
+
+		if !missing {
+			continue
+		}
+		if err := l.registry.Register(instance); err != nil {
+			logf("实例已从注册中心消失，重新注册失败，%v后重试: %v\n", retryInterval, err)
+			continue
+		}
+		missing = false
+		logf("实例已从注册中心消失，已重新注册: %s/%s %s\n", instance.Group, instance.Service, instance.Address())
+	}
+}
+
+// containsInstance 实例列表中是否包含指定ID的实例
+func containsInstance(instances []ServiceInstance, id string) bool {
+	for _, instance := range instances {
+		if instance.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// registered 实例是否已注册，返回注册中心中的实例
+func registered(r *MemoryRegistry, instance ServiceInstance) (ServiceInstance, bool) {
+	instances, _ := r.List(instance.Service, instance.Group)
+	for _, stored := range instances {
+		if stored.Address() == instance.Address() {
+			return stored, true
+		}
+	}
+	return ServiceInstance{}, false
+}
+
+func TestServiceLifecycle(t *testing.T) {
+	r := NewMemoryRegistry()
+	instance := testInstance("10.0.0.1", 8080)
+	lifecycle := NewServiceLifecycle(r, instance)
+	lifecycle.SetDrainDelay(200 * time.Millisecond)
+	lifecycle.SetRetryInterval(50 * time.Millisecond)
+	if err := lifecycle.Start(); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	defer lifecycle.Stop(context.Background())
+	if err := lifecycle.Start(); err != nil {
+		t.Fatalf("重复启动失败: %v", err)
+	}
+	if stored, ok := registered(r, instance); !ok || !stored.Enabled {
+		t.Fatalf("启动后实例为%+v，是否注册: %v", stored, ok)
+	}
+
+	// 实例从注册中心消失后自动重新注册
+	if err := r.Deregister(instance); err != nil {
+		t.Fatalf("注销实例失败: %v", err)
+	}
+	waitFor(t, "重新注册", func() bool {
+		_, ok := registered(r, instance)
+		return ok
+	})
+
+	// Stop时先禁用实例，等待drainDelay后再注销
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates, err := r.Watch(ctx, instance.Service, instance.Group)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	receive(t, updates)
+	stopped := make(chan error, 1)
+	start := time.Now()
+	go func() { stopped <- lifecycle.Stop(context.Background()) }()
+
+	instances := receive(t, updates)
+	if len(instances) != 1 || instances[0].Enabled {
+		t.Fatalf("Stop后首先推送的实例列表为%v，期望实例被禁用", instances)
+	}
+	if instances = receive(t, updates); len(instances) != 0 {
+		t.Fatalf("禁用后推送的实例列表为%v，期望实例被注销", instances)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("禁用后只等待了%v就注销", elapsed)
+	}
+
+	// 停止后不再重新注册
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := registered(r, instance); ok {
+		t.Error("Stop后实例又被重新注册")
+	}
+}
+
+func TestServiceLifecycleStopDeadline(t *testing.T) {
+	r := NewMemoryRegistry()
+	instance := testInstance("10.0.0.1", 8080)
+	lifecycle := NewServiceLifecycle(r, instance)
+	lifecycle.SetDrainDelay(time.Minute)
+	if err := lifecycle.Start(); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+
+	// ctx先到期时不再等待drainDelay，但仍然注销
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := lifecycle.Stop(ctx); err != nil {
+		t.Fatalf("Stop失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ctx到期后仍等待了%v", elapsed)
+	}
+	if _, ok := registered(r, instance); ok {
+		t.Error("ctx到期后实例没有注销")
+	}
+
+	// 重复Stop是幂等的，Stop之后可以再次Start
+	if err := lifecycle.Stop(context.Background()); err != nil {
+		t.Fatalf("重复Stop失败: %v", err)
+	}
+	if err := lifecycle.Start(); err != nil {
+		t.Fatalf("再次启动失败: %v", err)
+	}
+	if _, ok := registered(r, instance); !ok {
+		t.Error("再次启动后实例没有注册")
+	}
+	lifecycle.SetDrainDelay(0)
+	if err := lifecycle.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop失败: %v", err)
+	}
+}
+
+func TestServiceLifecycleRejectsInvalidInstance(t *testing.T) {
+	lifecycle := NewServiceLifecycle(NewMemoryRegistry(), ServiceInstance{Service: "myservice"})
+	if err := lifecycle.Start(); err == nil {
+		t.Error("IP和端口为空时应该启动失败")
+	}
+	if err := lifecycle.Stop(context.Background()); err != nil {
+		t.Errorf("没有启动时Stop返回%v", err)
+	}
+}
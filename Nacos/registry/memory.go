@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+)
+
+// MemoryRegistry 进程内的Registry实现，用于测试和本地调试
+// 变更会同步推送给所有Watch的调用方；SetHealthy可以模拟心跳超时导致的实例不健康
+type MemoryRegistry struct {
+	mutex    sync.Mutex
+	services map[string]map[string]ServiceInstance //group@@service -> 实例ID -> 实例
+	watchers map[string][]*instanceFeed
+}
+
+// NewMemoryRegistry 创建进程内注册中心
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		services: make(map[string]map[string]ServiceInstance),
+		watchers: make(map[string][]*instanceFeed),
+	}
+}
+
+// Register 实现Registry接口
+func (r *MemoryRegistry) Register(instance ServiceInstance) error {
+	instance, err := normalizeInstance(instance)
+	if err != nil {
+		return err
+	}
+	instance.Healthy = true
+	instance.Enabled = true
+	instance.Metadata = copyMetadata(instance.Metadata)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := memoryServiceKey(instance.Service, instance.Group)
+	if r.services[key] == nil {
+		r.services[key] = make(map[string]ServiceInstance)
+	}
+	r.services[key][instance.ID] = instance
+	r.notify(key)
+	return nil
+}
+
+// Deregister 实现Registry接口
+func (r *MemoryRegistry) Deregister(instance ServiceInstance) error {
+	instance, err := normalizeInstance(instance)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := memoryServiceKey(instance.Service, instance.Group)
+	if _, ok := r.services[key][instance.ID]; ok {
+		delete(r.services[key], instance.ID)
+		r.notify(key)
+	}
+	return nil
+}
+
+// SetHealthy 修改实例的健康状态，模拟心跳超时或恢复
+func (r *MemoryRegistry) SetHealthy(instance ServiceInstance, healthy bool) error {
+	return r.modify(instance, func(stored *ServiceInstance) { stored.Healthy = healthy })
+}
+
+// SetEnabled 实现Registry接口
+func (r *MemoryRegistry) SetEnabled(instance ServiceInstance, enabled bool) error {
+	return r.modify(instance, func(stored *ServiceInstance) { stored.Enabled = enabled })
+}
+
+// modify 修改已注册的实例并通知订阅者
+func (r *MemoryRegistry) modify(instance ServiceInstance, change func(*ServiceInstance)) error {
+	instance, err := normalizeInstance(instance)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := memoryServiceKey(instance.Service, instance.Group)
+	stored, ok := r.services[key][instance.ID]
+	if !ok {
+		return fmt.Errorf("实例%s不存在", instance.ID)
+	}
+	change(&stored)
+	r.services[key][instance.ID] = stored
+	r.notify(key)
+	return nil
+}
+
+// List 实现Registry接口
+func (r *MemoryRegistry) List(service, group string) ([]ServiceInstance, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.snapshot(memoryServiceKey(service, group)), nil
+}
+
+// Watch 实现Registry接口
+func (r *MemoryRegistry) Watch(ctx context.Context, service, group string) (<-chan []ServiceInstance, error) {
+	key := memoryServiceKey(service, group)
+	feed := newInstanceFeed()
+
+	r.mutex.Lock()
+	r.watchers[key] = append(r.watchers[key], feed)
+	feed.publish(r.snapshot(key))
+	r.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.mutex.Lock()
+		watchers := r.watchers[key]
+		for i, w := range watchers {
+			if w == feed {
+				r.watchers[key] = append(watchers[:i:i], watchers[i+1:]...)
+				break
+			}
+		}
+		r.mutex.Unlock()
+		feed.close()
+	}()
+	return feed.out, nil
+}
+
+// notify 向订阅者推送最新列表，调用方需持有mutex
+func (r *MemoryRegistry) notify(key string) {
+	for _, feed := range r.watchers[key] {
+		feed.publish(r.snapshot(key))
+	}
+}
+
+// snapshot 返回实例列表的副本，调用方需持有mutex
+func (r *MemoryRegistry) snapshot(key string) []ServiceInstance {
+	instances := make([]ServiceInstance, 0, len(r.services[key]))
+	for _, instance := range r.services[key] {
+		instance.Metadata = copyMetadata(instance.Metadata)
+		instances = append(instances, instance)
+	}
+	sortInstances(instances)
+	return instances
+}
+
+// memoryServiceKey 服务的唯一标识
+func memoryServiceKey(service, group string) string {
+	return defaultGroup(group) + constant.SERVICE_INFO_SPLITER + service
+}
+
+// copyMetadata 复制元数据，避免调用方修改影响注册中心中的数据
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testInstance 生成myservice在DEFAULT_GROUP中的实例
+func testInstance(ip string, port uint64) ServiceInstance {
+	return ServiceInstance{Service: "myservice", Ip: ip, Port: port}
+}
+
+// receive 等待Watch推送下一份实例列表
+func receive(t *testing.T, updates <-chan []ServiceInstance) []ServiceInstance {
+	t.Helper()
+	select {
+	case instances, ok := <-updates:
+		if !ok {
+			t.Fatal("Watch的channel已关闭")
+		}
+		return instances
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待实例列表推送超时")
+		return nil
+	}
+}
+
+// addresses 返回实例地址列表，用于比较顺序
+func addresses(instances []ServiceInstance) []string {
+	var out []string
+	for _, instance := range instances {
+		out = append(out, instance.Address())
+	}
+	return out
+}
+
+func TestMemoryRegistryRegisterAndList(t *testing.T) {
+	r := NewMemoryRegistry()
+	for _, instance := range []ServiceInstance{
+		testInstance("10.0.0.3", 8080),
+		testInstance("10.0.0.1", 8080),
+		testInstance("10.0.0.2", 8080),
+	} {
+		if err := r.Register(instance); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+	}
+	if err := r.Register(ServiceInstance{Ip: "10.0.0.4", Port: 8080}); err == nil {
+		t.Error("服务名为空时应该注册失败")
+	}
+	if err := r.Register(ServiceInstance{Service: "myservice"}); err == nil {
+		t.Error("IP和端口为空时应该注册失败")
+	}
+
+	// 按实例ID排序，补全默认的分组、集群和权重
+	instances, err := r.List("myservice", "")
+	if err != nil {
+		t.Fatalf("查询实例失败: %v", err)
+	}
+	if got := addresses(instances); len(got) != 3 || got[0] != "10.0.0.1:8080" || got[1] != "10.0.0.2:8080" || got[2] != "10.0.0.3:8080" {
+		t.Fatalf("实例列表为%v，期望按实例ID排序", got)
+	}
+	first := instances[0]
+	if first.ID != "10.0.0.1#8080#DEFAULT#DEFAULT_GROUP@@myservice" || first.Group != "DEFAULT_GROUP" || first.Cluster != DefaultCluster ||
+		first.Weight != 1 || !first.Healthy || !first.Enabled {
+		t.Errorf("注册后的实例为%+v", first)
+	}
+	if other, _ := r.List("myservice", "OTHER_GROUP"); len(other) != 0 {
+		t.Errorf("其他分组不应有实例: %v", other)
+	}
+
+	// 禁用后仍在列表中，但不再接收流量
+	if err := r.SetEnabled(testInstance("10.0.0.2", 8080), false); err != nil {
+		t.Fatalf("禁用实例失败: %v", err)
+	}
+	instances, _ = r.List("myservice", "DEFAULT_GROUP")
+	if len(instances) != 3 || instances[1].Enabled || len(HealthyInstances(instances)) != 2 {
+		t.Errorf("禁用后的实例列表为%v", instances)
+	}
+	if err := r.SetEnabled(testInstance("10.0.0.9", 8080), false); err == nil {
+		t.Error("禁用不存在的实例应该返回错误")
+	}
+
+	// 重复注销不报错
+	for i := 0; i < 2; i++ {
+		if err := r.Deregister(testInstance("10.0.0.1", 8080)); err != nil {
+			t.Fatalf("第%d次注销实例失败: %v", i+1, err)
+		}
+	}
+	instances, _ = r.List("myservice", "")
+	if got := addresses(instances); len(got) != 2 || got[0] != "10.0.0.2:8080" || got[1] != "10.0.0.3:8080" {
+		t.Errorf("注销后的实例列表为%v", got)
+	}
+}
+
+func TestMemoryRegistryCopiesMetadata(t *testing.T) {
+	r := NewMemoryRegistry()
+	instance := testInstance("10.0.0.1", 8080)
+	instance.Metadata = map[string]string{MetadataVersion: "v1"}
+	if err := r.Register(instance); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	instance.Metadata[MetadataVersion] = "v2"
+
+	instances, _ := r.List("myservice", "")
+	instances[0].Metadata[MetadataVersion] = "v3"
+	instances, _ = r.List("myservice", "")
+	if version := instances[0].Metadata[MetadataVersion]; version != "v1" {
+		t.Errorf("调用方修改元数据影响了注册中心，version为%s", version)
+	}
+}
+
+func TestMemoryRegistryWatch(t *testing.T) {
+	r := NewMemoryRegistry()
+	if err := r.Register(testInstance("10.0.0.1", 8080)); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates, err := r.Watch(ctx, "myservice", "")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 先推送当前的实例列表
+	if got := addresses(receive(t, updates)); len(got) != 1 || got[0] != "10.0.0.1:8080" {
+		t.Fatalf("首次推送的实例列表为%v", got)
+	}
+
+	// 消费者没有取走时只保留最新的列表
+	r.Register(testInstance("10.0.0.2", 8080))
+	r.Register(testInstance("10.0.0.3", 8080))
+	r.Deregister(testInstance("10.0.0.1", 8080))
+	if got := addresses(receive(t, updates)); len(got) != 2 || got[0] != "10.0.0.2:8080" || got[1] != "10.0.0.3:8080" {
+		t.Fatalf("合并后推送的实例列表为%v", got)
+	}
+	select {
+	case instances := <-updates:
+		t.Fatalf("不应再推送旧的实例列表: %v", addresses(instances))
+	default:
+	}
+
+	// 修改状态同样推送
+	r.SetEnabled(testInstance("10.0.0.2", 8080), false)
+	if instances := receive(t, updates); len(instances) != 2 || instances[0].Enabled {
+		t.Errorf("禁用后推送的实例列表为%v", instances)
+	}
+}
+
+func TestMemoryRegistryWatchClosesOnCancel(t *testing.T) {
+	r := NewMemoryRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := r.Watch(ctx, "myservice", "")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	if instances := receive(t, updates); len(instances) != 0 {
+		t.Fatalf("没有实例时首次推送%v", instances)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("取消后不应再推送实例列表")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("取消后channel没有关闭")
+	}
+
+	// 取消后的变更不再推送，也不会阻塞注册
+	if err := r.Register(testInstance("10.0.0.1", 8080)); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	r.mutex.Lock()
+	watchers := len(r.watchers[memoryServiceKey("myservice", "")])
+	r.mutex.Unlock()
+	if watchers != 0 {
+		t.Errorf("取消后仍有%d个订阅者", watchers)
+	}
+}
@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"strings"
+)
+
+// 实例元数据约定：Nacos的元数据是字符串map，这里约定版本、可用区、协议和标签的key，
+// 注册方用InstanceMetadata写入，消费方用Selector按标签筛选实例，实现灰度和蓝绿发布的路由
+
+// 实例元数据中约定的key
+const (
+	MetadataVersion  = "version"  //服务版本，例如 v2
+	MetadataZone     = "zone"     //可用区，例如 cn-east
+	MetadataProtocol = "protocol" //通信协议，例如 http、grpc
+	MetadataTags     = "tags"     //标签，逗号分隔，例如 canary,blue
+)
+
+// InstanceMetadata 结构化的实例元数据
+type InstanceMetadata struct {
+	Version  string
+	Zone     string
+	Protocol string
+	Tags     []string
+	Extra    map[string]string //其他自定义元数据
+}
+
+// ParseInstanceMetadata 从Nacos的元数据中解析约定的字段，其余字段放入Extra
+func ParseInstanceMetadata(metadata map[string]string) InstanceMetadata {
+	var meta InstanceMetadata
+	for key, value := range metadata {
+		switch key {
+		case MetadataVersion:
+			meta.Version = value
+		case MetadataZone:
+			meta.Zone = value
+		case MetadataProtocol:
+			meta.Protocol = value
+		case MetadataTags:
+			meta.Tags = splitTags(value)
+		default:
+			if meta.Extra == nil {
+				meta.Extra = make(map[string]string)
+			}
+			meta.Extra[key] = value
+		}
+	}
+	return meta
+}
+
+// Map 转换为Nacos的元数据，空字段不写入
+func (m InstanceMetadata) Map() map[string]string {
+	metadata := make(map[string]string, len(m.Extra)+4)
+	for key, value := range m.Extra {
+		metadata[key] = value
+	}
+	if m.Version != "" {
+		metadata[MetadataVersion] = m.Version
+	}
+	if m.Zone != "" {
+		metadata[MetadataZone] = m.Zone
+	}
+	if m.Protocol != "" {
+		metadata[MetadataProtocol] = m.Protocol
+	}
+	if tags := splitTags(strings.Join(m.Tags, ",")); len(tags) > 0 {
+		metadata[MetadataTags] = strings.Join(tags, ",")
+	}
+	return metadata
+}
+
+// HasTag 是否带有指定标签
+func (m InstanceMetadata) HasTag(tag string) bool {
+	return containsString(m.Tags, tag)
+}
+
+// Meta 解析实例的结构化元数据
+func (i ServiceInstance) Meta() InstanceMetadata {
+	return ParseInstanceMetadata(i.Metadata)
+}
+
+// WithMetadata 返回合并了结构化元数据的实例副本，同名的key以meta为准
+func (i ServiceInstance) WithMetadata(meta InstanceMetadata) ServiceInstance {
+	metadata := copyMetadata(i.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	for key, value := range meta.Map() {
+		metadata[key] = value
+	}
+	i.Metadata = metadata
+	return i
+}
+
+// splitTags 拆分逗号分隔的标签，去掉空白和空标签
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// containsString 列表中是否包含s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInstanceMetadata(t *testing.T) {
+	meta := ParseInstanceMetadata(map[string]string{
+		MetadataVersion:  "v2",
+		MetadataZone:     "cn-east",
+		MetadataProtocol: "grpc",
+		MetadataTags:     " canary, ,blue ",
+		"owner":          "team-a",
+	})
+	want := InstanceMetadata{
+		Version:  "v2",
+		Zone:     "cn-east",
+		Protocol: "grpc",
+		Tags:     []string{"canary", "blue"},
+		Extra:    map[string]string{"owner": "team-a"},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("解析结果为%+v，期望%+v", meta, want)
+	}
+	if !meta.HasTag("blue") || meta.HasTag("green") {
+		t.Errorf("HasTag结果不正确: %v", meta.Tags)
+	}
+
+	if meta := ParseInstanceMetadata(nil); !reflect.DeepEqual(meta, InstanceMetadata{}) {
+		t.Errorf("没有元数据时解析结果为%+v", meta)
+	}
+}
+
+func TestInstanceMetadataMap(t *testing.T) {
+	tests := []struct {
+		name string
+		meta InstanceMetadata
+		want map[string]string
+	}{
+		{"空字段不写入", InstanceMetadata{}, map[string]string{}},
+		{"约定字段", InstanceMetadata{Version: "v1", Zone: "cn-west", Protocol: "http"},
+			map[string]string{MetadataVersion: "v1", MetadataZone: "cn-west", MetadataProtocol: "http"}},
+		{"标签去掉空白和空标签", InstanceMetadata{Tags: []string{" canary", "", "blue "}}, map[string]string{MetadataTags: "canary,blue"}},
+		{"只有空标签时不写入", InstanceMetadata{Tags: []string{" ", ""}}, map[string]string{}},
+		{"约定字段覆盖Extra中的同名key", InstanceMetadata{Version: "v2", Extra: map[string]string{MetadataVersion: "v1", "owner": "team-a"}},
+			map[string]string{MetadataVersion: "v2", "owner": "team-a"}},
+	}
+	for _, tt := range tests {
+		if got := tt.meta.Map(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Map返回%v，期望%v", tt.name, got, tt.want)
+		}
+	}
+
+	// 解析Map的结果得到相同的元数据
+	meta := InstanceMetadata{Version: "v2", Zone: "cn-east", Tags: []string{"canary"}, Extra: map[string]string{"owner": "team-a"}}
+	if got := ParseInstanceMetadata(meta.Map()); !reflect.DeepEqual(got, meta) {
+		t.Errorf("往返转换后为%+v，期望%+v", got, meta)
+	}
+}
+
+func TestServiceInstanceWithMetadata(t *testing.T) {
+	instance := ServiceInstance{Metadata: map[string]string{MetadataVersion: "v1", "owner": "team-a"}}
+	updated := instance.WithMetadata(InstanceMetadata{Version: "v2", Tags: []string{"canary"}})
+
+	want := map[string]string{MetadataVersion: "v2", "owner": "team-a", MetadataTags: "canary"}
+	if !reflect.DeepEqual(updated.Metadata, want) {
+		t.Errorf("合并后的元数据为%v，期望%v", updated.Metadata, want)
+	}
+	if instance.Metadata[MetadataVersion] != "v1" || len(instance.Metadata) != 2 {
+		t.Errorf("WithMetadata修改了原实例的元数据: %v", instance.Metadata)
+	}
+	if meta := updated.Meta(); meta.Version != "v2" || !meta.HasTag("canary") {
+		t.Errorf("Meta返回%+v", meta)
+	}
+
+	// 原实例没有元数据时同样可以合并
+	if got := (ServiceInstance{}).WithMetadata(InstanceMetadata{Zone: "cn-east"}).Metadata; !reflect.DeepEqual(got, map[string]string{MetadataZone: "cn-east"}) {
+		t.Errorf("合并到空元数据的结果为%v", got)
+	}
+}
@@ -0,0 +1,248 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// NacosRegistry 基于Nacos服务发现客户端的Registry实现
+// 客户端需要开启ClientConfig.UpdateCacheWhenEmpty，否则服务的最后一个实例下线后，
+// SDK会忽略空的实例列表，List和Watch仍然返回下线前的实例
+type NacosRegistry struct {
+	client        naming_client.INamingClient
+	mutex         sync.Mutex
+	subscriptions map[string]*nacosSubscription //group@@service -> 该服务全部Watch共享的SDK订阅
+}
+
+// nacosSubscription 同一个服务的SDK订阅，回调分发给该服务的全部Watch
+// SDK按服务保存回调列表，Subscribe/Unsubscribe并发修改同一个列表时可能丢失回调，
+// 实例列表为空时也只回调列表中的第一个，因此每个服务只向SDK订阅一次
+type nacosSubscription struct {
+	param *vo.SubscribeParam //SDK按SubscribeCallback字段的地址注销回调，Unsubscribe必须传同一个param
+	mutex sync.Mutex
+	feeds map[*instanceFeed]bool
+}
+
+// NewNacosRegistry 使用已创建的服务发现客户端
+func NewNacosRegistry(client naming_client.INamingClient) *NacosRegistry {
+	return &NacosRegistry{
+		client:        client,
+		subscriptions: make(map[string]*nacosSubscription),
+	}
+}
+
+// Register 实现Registry接口
+func (r *NacosRegistry) Register(instance ServiceInstance) error {
+	instance, err := normalizeInstance(instance)
+	if err != nil {
+		return err
+	}
+	ok, err := r.client.RegisterInstance(vo.RegisterInstanceParam{
+		ServiceName: instance.Service,
+		GroupName:   instance.Group,
+		ClusterName: instance.Cluster,
+		Ip:          instance.Ip,
+		Port:        instance.Port,
+		Weight:      instance.Weight,
+		Metadata:    instance.Metadata,
+		Enable:      true,
+		Healthy:     true,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("注册实例%s失败: %v", instance, err)
+	}
+	if !ok {
+		return fmt.Errorf("注册实例%s失败", instance)
+	}
+	return nil
+}
+
+// Deregister 实现Registry接口
+func (r *NacosRegistry) Deregister(instance ServiceInstance) error {
+	instance, err := normalizeInstance(instance)
+	if err != nil {
+		return err
+	}
+	ok, err := r.client.DeregisterInstance(vo.DeregisterInstanceParam{
+		ServiceName: instance.Service,
+		GroupName:   instance.Group,
+		Cluster:     instance.Cluster,
+		Ip:          instance.Ip,
+		Port:        instance.Port,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("注销实例%s失败: %v", instance, err)
+	}
+	if !ok {
+		return fmt.Errorf("注销实例%s失败", instance)
+	}
+	return nil
+}
+
+// SetEnabled 实现Registry接口
+func (r *NacosRegistry) SetEnabled(instance ServiceInstance, enabled bool) error {
+	instance, err := normalizeInstance(instance)
+	if err != nil {
+		return err
+	}
+	// 服务端按请求中的字段整体更新实例，权重和元数据需要一起带上
+	ok, err := r.client.UpdateInstance(vo.UpdateInstanceParam{
+		ServiceName: instance.Service,
+		GroupName:   instance.Group,
+		ClusterName: instance.Cluster,
+		Ip:          instance.Ip,
+		Port:        instance.Port,
+		Weight:      instance.Weight,
+		Metadata:    instance.Metadata,
+		Enable:      enabled,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("修改实例%s的状态失败: %v", instance, err)
+	}
+	if !ok {
+		return fmt.Errorf("修改实例%s的状态失败", instance)
+	}
+	return nil
+}
+
+// List 实现Registry接口，返回SDK本地缓存中的实例，与Watch的回调来自同一份缓存
+func (r *NacosRegistry) List(service, group string) ([]ServiceInstance, error) {
+	group = defaultGroup(group)
+	hosts, err := r.client.SelectAllInstances(vo.SelectAllInstancesParam{
+		ServiceName: service,
+		GroupName:   group,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询服务[%s/%s]的实例失败: %v", group, service, err)
+	}
+	instances := make([]ServiceInstance, 0, len(hosts))
+	for _, host := range hosts {
+		instances = append(instances, fromNacosInstance(service, group, host))
+	}
+	sortInstances(instances)
+	return instances, nil
+}
+
+// Watch 实现Registry接口，基于SDK的Subscribe，实例列表由SDK按服务端返回的cacheMillis定时刷新；
+// 同一个服务的多个Watch共享一个SDK订阅，最后一个Watch结束时才取消订阅
+func (r *NacosRegistry) Watch(ctx context.Context, service, group string) (<-chan []ServiceInstance, error) {
+	group = defaultGroup(group)
+	key := memoryServiceKey(service, group)
+	feed := newInstanceFeed()
+
+	r.mutex.Lock()
+	subscription, ok := r.subscriptions[key]
+	if !ok {
+		subscription = &nacosSubscription{feeds: make(map[*instanceFeed]bool)}
+		subscription.param = &vo.SubscribeParam{
+			ServiceName: service,
+			GroupName:   group,
+			SubscribeCallback: func(services []model.SubscribeService, err error) {
+				// SDK只在实例列表为空时返回错误，此时services为空，按空列表推送
+				instances := make([]ServiceInstance, 0, len(services))
+				for _, s := range services {
+					instances = append(instances, fromSubscribeService(service, group, s))
+				}
+				sortInstances(instances)
+				subscription.publish(instances)
+			},
+		}
+		if err := r.client.Subscribe(subscription.param); err != nil {
+			// SDK在查询服务之前就登记了回调，失败时同样需要注销
+			r.client.Unsubscribe(subscription.param)
+			r.mutex.Unlock()
+			return nil, fmt.Errorf("订阅服务[%s/%s]失败: %v", group, service, err)
+		}
+		r.subscriptions[key] = subscription
+	}
+	subscription.add(feed)
+	r.mutex.Unlock()
+
+	// 客户端没有开启NotLoadCacheAtStart时Subscribe不会立即回调，共享订阅时也不会再回调，主动推送一次当前列表
+	if instances, err := r.List(service, group); err == nil {
+		feed.publish(instances)
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		if subscription.remove(feed) {
+			return
+		}
+		delete(r.subscriptions, key)
+		if err := r.client.Unsubscribe(subscription.param); err != nil {
+			logf("取消订阅服务[%s/%s]失败: %v\n", group, service, err)
+		}
+	}()
+	return feed.out, nil
+}
+
+// add 添加一个Watch的推送通道
+func (s *nacosSubscription) add(feed *instanceFeed) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.feeds[feed] = true
+}
+
+// remove 移除并关闭一个Watch的推送通道，返回是否还有其他Watch
+func (s *nacosSubscription) remove(feed *instanceFeed) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.feeds, feed)
+	feed.close()
+	return len(s.feeds) > 0
+}
+
+// publish 把SDK回调的实例列表推送给全部Watch，每个Watch拿到独立的切片
+func (s *nacosSubscription) publish(instances []ServiceInstance) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for feed := range s.feeds {
+		feed.publish(append([]ServiceInstance(nil), instances...))
+	}
+}
+
+// fromNacosInstance 转换SDK的实例结构
+func fromNacosInstance(service, group string, host model.Instance) ServiceInstance {
+	return ServiceInstance{
+		ID:       host.InstanceId,
+		Service:  service,
+		Group:    group,
+		Cluster:  host.ClusterName,
+		Ip:       host.Ip,
+		Port:     host.Port,
+		Weight:   host.Weight,
+		Healthy:  host.Healthy,
+		Enabled:  host.Enable,
+		Metadata: host.Metadata,
+	}
+}
+
+// fromSubscribeService 转换SDK订阅回调中的实例结构
+func fromSubscribeService(service, group string, s model.SubscribeService) ServiceInstance {
+	return ServiceInstance{
+		ID:       s.InstanceId,
+		Service:  service,
+		Group:    group,
+		Cluster:  s.ClusterName,
+		Ip:       s.Ip,
+		Port:     s.Port,
+		Weight:   s.Weight,
+		Healthy:  s.Healthy,
+		Enabled:  s.Enable,
+		Metadata: s.Metadata,
+	}
+}
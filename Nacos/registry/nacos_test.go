@@ -0,0 +1,170 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// fakeNamingClient 只实现订阅相关方法的服务发现客户端，
+// 与SDK一样按SubscribeCallback字段的地址区分回调
+type fakeNamingClient struct {
+	naming_client.INamingClient
+	mutex        sync.Mutex
+	hosts        []model.Instance
+	callbacks    map[*func(services []model.SubscribeService, err error)]bool
+	subscribes   int
+	unsubscribes int
+}
+
+// newFakeNamingClient 创建假的服务发现客户端
+func newFakeNamingClient() *fakeNamingClient {
+	return &fakeNamingClient{callbacks: make(map[*func(services []model.SubscribeService, err error)]bool)}
+}
+
+// Subscribe 登记回调
+func (c *fakeNamingClient) Subscribe(param *vo.SubscribeParam) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.subscribes++
+	c.callbacks[&param.SubscribeCallback] = true
+	return nil
+}
+
+// Unsubscribe 注销回调
+func (c *fakeNamingClient) Unsubscribe(param *vo.SubscribeParam) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.unsubscribes++
+	delete(c.callbacks, &param.SubscribeCallback)
+	return nil
+}
+
+// SelectAllInstances 返回当前的实例列表
+func (c *fakeNamingClient) SelectAllInstances(param vo.SelectAllInstancesParam) ([]model.Instance, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]model.Instance(nil), c.hosts...), nil
+}
+
+// push 修改实例列表并回调全部订阅
+func (c *fakeNamingClient) push(ips ...string) {
+	c.mutex.Lock()
+	c.hosts = nil
+	var services []model.SubscribeService
+	for _, ip := range ips {
+		id := ip + "#8080#DEFAULT#DEFAULT_GROUP@@myservice"
+		c.hosts = append(c.hosts, model.Instance{InstanceId: id, Ip: ip, Port: 8080, Weight: 1, Healthy: true, Enable: true})
+		services = append(services, model.SubscribeService{InstanceId: id, Ip: ip, Port: 8080, Weight: 1, Healthy: true, Enable: true})
+	}
+	var callbacks []*func(services []model.SubscribeService, err error)
+	for callback := range c.callbacks {
+		callbacks = append(callbacks, callback)
+	}
+	c.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		(*callback)(services, nil)
+	}
+}
+
+// counts 返回Subscribe、Unsubscribe的调用次数和仍登记的回调数
+func (c *fakeNamingClient) counts() (int, int, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.subscribes, c.unsubscribes, len(c.callbacks)
+}
+
+// waitClosed 等待Watch的channel关闭，并等待取消订阅的处理结束
+func waitClosed(t *testing.T, r *NacosRegistry, updates <-chan []ServiceInstance) {
+	t.Helper()
+	for {
+		select {
+		case _, ok := <-updates:
+			if !ok {
+				// 关闭channel和注销SDK回调都在持有mutex时完成
+				r.mutex.Lock()
+				r.mutex.Unlock()
+				return
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("取消后channel没有关闭")
+		}
+	}
+}
+
+func TestNacosRegistrySharedSubscription(t *testing.T) {
+	client := newFakeNamingClient()
+	client.push("10.0.0.1")
+	r := NewNacosRegistry(client)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	first, err := r.Watch(ctx1, "myservice", "")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	second, err := r.Watch(ctx2, "myservice", "DEFAULT_GROUP")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 同一个服务只向SDK订阅一次，两个Watch都先收到当前列表
+	if subscribes, _, callbacks := client.counts(); subscribes != 1 || callbacks != 1 {
+		t.Fatalf("两个Watch向SDK订阅了%d次，登记了%d个回调", subscribes, callbacks)
+	}
+	for _, updates := range []<-chan []ServiceInstance{first, second} {
+		if got := addresses(receive(t, updates)); len(got) != 1 || got[0] != "10.0.0.1:8080" {
+			t.Fatalf("首次推送的实例列表为%v", got)
+		}
+	}
+
+	// 回调分发给全部Watch
+	client.push("10.0.0.1", "10.0.0.2")
+	for _, updates := range []<-chan []ServiceInstance{first, second} {
+		if instances := receive(t, updates); len(instances) != 2 || instances[1].Group != "DEFAULT_GROUP" || instances[1].Service != "myservice" {
+			t.Fatalf("回调推送的实例列表为%v", instances)
+		}
+	}
+
+	// 取消一个Watch不影响另一个，也不取消SDK订阅
+	cancel1()
+	waitClosed(t, r, first)
+	if _, unsubscribes, callbacks := client.counts(); unsubscribes != 0 || callbacks != 1 {
+		t.Fatalf("还有Watch时取消了SDK订阅，Unsubscribe调用%d次，剩余%d个回调", unsubscribes, callbacks)
+	}
+	client.push("10.0.0.2")
+	if got := addresses(receive(t, second)); len(got) != 1 || got[0] != "10.0.0.2:8080" {
+		t.Fatalf("取消另一个Watch后推送的实例列表为%v", got)
+	}
+
+	// 最后一个Watch结束时注销同一个回调
+	cancel2()
+	waitClosed(t, r, second)
+	if _, unsubscribes, callbacks := client.counts(); unsubscribes != 1 || callbacks != 0 {
+		t.Fatalf("全部Watch结束后Unsubscribe调用%d次，剩余%d个回调", unsubscribes, callbacks)
+	}
+
+	// 之后重新Watch会重新订阅
+	ctx3, cancel3 := context.WithCancel(context.Background())
+	defer cancel3()
+	third, err := r.Watch(ctx3, "myservice", "")
+	if err != nil {
+		t.Fatalf("重新订阅失败: %v", err)
+	}
+	receive(t, third)
+	if subscribes, _, callbacks := client.counts(); subscribes != 2 || callbacks != 1 {
+		t.Errorf("重新Watch后Subscribe调用%d次，登记了%d个回调", subscribes, callbacks)
+	}
+}
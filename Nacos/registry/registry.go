@@ -0,0 +1,151 @@
+// Package registry 服务注册与发现的抽象：业务代码只依赖Registry接口，
+// 生产环境使用NacosRegistry，测试中使用MemoryRegistry，不需要启动Nacos
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+)
+
+// DefaultCluster 集群为空时使用的集群名，与Nacos的默认集群一致
+const DefaultCluster = "DEFAULT"
+
+// Registry 服务注册中心
+type Registry interface {
+	// Register 注册实例，同一个实例重复注册会覆盖之前的信息
+	Register(instance ServiceInstance) error
+	// Deregister 注销实例，实例不存在时不报错
+	Deregister(instance ServiceInstance) error
+	// SetEnabled 修改已注册实例是否接收流量，下线前先禁用实例，让消费者停止发送新请求
+	SetEnabled(instance ServiceInstance, enabled bool) error
+	// List 返回服务当前的全部实例(包括不健康和已禁用的)，按实例ID排序
+	List(service, group string) ([]ServiceInstance, error)
+	// Watch 订阅服务的实例变化，先推送一次当前的实例列表，之后每次变化推送完整列表；
+	// 消费者处理不过来时只保留最新的列表，ctx结束后取消订阅并关闭channel
+	Watch(ctx context.Context, service, group string) (<-chan []ServiceInstance, error)
+}
+
+// ServiceInstance 服务实例
+// Register总是注册为启用、健康的临时实例(依靠心跳保活)，Healthy和Enabled只在查询结果中有意义；
+// 临时实例的健康状态由心跳决定，客户端只能通过SetEnabled修改是否接收流量
+type ServiceInstance struct {
+	ID       string            //实例ID，由注册中心生成，注册时可以为空
+	Service  string            //服务名
+	Group    string            //分组，为空时使用DEFAULT_GROUP
+	Cluster  string            //集群，为空时使用DEFAULT
+	Ip       string            //实例IP
+	Port     uint64            //实例端口
+	Weight   float64           //权重，注册时为0表示1
+	Healthy  bool              //是否健康
+	Enabled  bool              //是否接收流量
+	Metadata map[string]string //元数据
+}
+
+// Address 返回 ip:port
+func (i ServiceInstance) Address() string {
+	return net.JoinHostPort(i.Ip, strconv.FormatUint(i.Port, 10))
+}
+
+// String 用于日志输出
+func (i ServiceInstance) String() string {
+	return fmt.Sprintf("%s/%s(%s 权重:%g 健康:%t 启用:%t)", i.Group, i.Service, i.Address(), i.Weight, i.Healthy, i.Enabled)
+}
+
+// HealthyInstances 过滤出可以接收流量的实例：健康、已启用且权重大于0，与Nacos SDK的选择规则一致
+func HealthyInstances(instances []ServiceInstance) []ServiceInstance {
+	var healthy []ServiceInstance
+	for _, instance := range instances {
+		if instance.Healthy && instance.Enabled && instance.Weight > 0 {
+			healthy = append(healthy, instance)
+		}
+	}
+	return healthy
+}
+
+// normalizeInstance 补全注册时的默认值并校验必填项
+func normalizeInstance(instance ServiceInstance) (ServiceInstance, error) {
+	if instance.Service == "" {
+		return instance, fmt.Errorf("服务名不能为空")
+	}
+	if instance.Ip == "" || instance.Port == 0 {
+		return instance, fmt.Errorf("实例[%s]的IP和端口不能为空", instance.Service)
+	}
+	instance.Group = defaultGroup(instance.Group)
+	if instance.Cluster == "" {
+		instance.Cluster = DefaultCluster
+	}
+	if instance.Weight <= 0 {
+		instance.Weight = 1
+	}
+	if instance.ID == "" {
+		instance.ID = instanceID(instance)
+	}
+	return instance, nil
+}
+
+// instanceID 与Nacos相同格式的实例ID
+func instanceID(instance ServiceInstance) string {
+	return fmt.Sprintf("%s#%d#%s#%s%s%s", instance.Ip, instance.Port, instance.Cluster, instance.Group, constant.SERVICE_INFO_SPLITER, instance.Service)
+}
+
+// defaultGroup 分组为空时使用DEFAULT_GROUP
+func defaultGroup(group string) string {
+	if group == "" {
+		return constant.DEFAULT_GROUP
+	}
+	return group
+}
+
+// sortInstances 按实例ID排序，保证多次查询的结果顺序稳定
+func sortInstances(instances []ServiceInstance) {
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+}
+
+// instanceFeed 向Watch的调用方推送实例列表，channel只缓存最新的一份
+type instanceFeed struct {
+	mutex  sync.Mutex
+	out    chan []ServiceInstance
+	closed bool
+}
+
+// newInstanceFeed 创建推送通道
+func newInstanceFeed() *instanceFeed {
+	return &instanceFeed{out: make(chan []ServiceInstance, 1)}
+}
+
+// publish 推送最新的实例列表，丢弃消费者还没取走的旧列表
+func (f *instanceFeed) publish(instances []ServiceInstance) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return
+	}
+	select {
+	case <-f.out:
+	default:
+	}
+	f.out <- instances
+}
+
+// close 关闭推送通道，重复调用是安全的
+func (f *instanceFeed) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.closed {
+		f.closed = true
+		close(f.out)
+	}
+}
+
+// logf 输出日志，与主程序的日志格式保持一致
+func logf(format string, args ...interface{}) {
+	fmt.Printf(format, args...)
+}
@@ -0,0 +1,227 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 标签选择器，按实例元数据筛选实例，语法与Kubernetes的标签选择器类似，多个条件用逗号分隔且同时满足:
+//   version=v2              等于(也可以写成==)
+//   zone!=cn-east           不等于，没有该元数据的实例也满足
+//   version in (v1,v2)      属于其中之一
+//   zone notin (cn-east)    不属于其中任何一个，没有该元数据的实例也满足
+//   protocol                存在该元数据
+//   !canary                 不存在该元数据
+// tags按标签集合匹配：tags=canary表示带有canary标签，tags!=canary表示不带canary标签
+// 取值不能为空，version= 会被当作格式错误
+
+// Selector 解析后的标签选择器，零值匹配全部实例
+type Selector struct {
+	text         string
+	requirements []labelRequirement
+}
+
+// labelRequirement 单个条件
+type labelRequirement struct {
+	key    string
+	op     string
+	values []string
+}
+
+// 条件的运算符
+const (
+	selectorEquals    = "="
+	selectorNotEquals = "!="
+	selectorIn        = "in"
+	selectorNotIn     = "notin"
+	selectorExists    = "exists"
+	selectorNotExists = "!"
+)
+
+// ParseSelector 解析标签选择器，空字符串匹配全部实例
+func ParseSelector(text string) (Selector, error) {
+	selector := Selector{text: strings.TrimSpace(text)}
+	if selector.text == "" {
+		return selector, nil
+	}
+	terms, err := splitSelectorTerms(selector.text)
+	if err != nil {
+		return Selector{}, fmt.Errorf("标签选择器[%s]格式错误: %v", text, err)
+	}
+	for _, term := range terms {
+		requirement, err := parseRequirement(term)
+		if err != nil {
+			return Selector{}, fmt.Errorf("标签选择器[%s]格式错误: %v", text, err)
+		}
+		selector.requirements = append(selector.requirements, requirement)
+	}
+	return selector, nil
+}
+
+// MustParseSelector 解析标签选择器，格式错误时panic，用于常量表达式
+func MustParseSelector(text string) Selector {
+	selector, err := ParseSelector(text)
+	if err != nil {
+		panic(err)
+	}
+	return selector
+}
+
+// String 返回选择器的原始表达式
+func (s Selector) String() string {
+	return s.text
+}
+
+// Empty 是否没有任何条件
+func (s Selector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+// Matches 实例是否满足全部条件
+func (s Selector) Matches(instance ServiceInstance) bool {
+	for _, requirement := range s.requirements {
+		if !requirement.matches(instance.Metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter 返回满足条件的实例，保持原有顺序
+func (s Selector) Filter(instances []ServiceInstance) []ServiceInstance {
+	if s.Empty() {
+		return instances
+	}
+	matched := make([]ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if s.Matches(instance) {
+			matched = append(matched, instance)
+		}
+	}
+	return matched
+}
+
+// matches 单个条件是否满足
+func (r labelRequirement) matches(metadata map[string]string) bool {
+	values, ok := labelValues(metadata, r.key)
+	switch r.op {
+	case selectorExists:
+		return ok
+	case selectorNotExists:
+		return !ok
+	case selectorEquals, selectorIn:
+		return ok && containsAny(values, r.values)
+	default:
+		return !ok || !containsAny(values, r.values)
+	}
+}
+
+// labelValues 取出元数据的值，tags拆分为标签集合
+func labelValues(metadata map[string]string, key string) ([]string, bool) {
+	value, ok := metadata[key]
+	if !ok {
+		return nil, false
+	}
+	if key == MetadataTags {
+		tags := splitTags(value)
+		return tags, len(tags) > 0
+	}
+	return []string{value}, true
+}
+
+// containsAny 两个集合是否有交集
+func containsAny(values, candidates []string) bool {
+	for _, candidate := range candidates {
+		if containsString(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSelectorTerms 按括号外的逗号拆分条件
+func splitSelectorTerms(text string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("括号不能嵌套")
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("括号不匹配")
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, strings.TrimSpace(text[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("括号不匹配")
+	}
+	terms = append(terms, strings.TrimSpace(text[start:]))
+	for _, term := range terms {
+		if term == "" {
+			return nil, fmt.Errorf("存在空条件")
+		}
+	}
+	return terms, nil
+}
+
+// parseRequirement 解析单个条件
+func parseRequirement(term string) (labelRequirement, error) {
+	if open := strings.Index(term, "("); open >= 0 {
+		head := strings.Fields(term[:open])
+		if len(head) != 2 || (head[1] != selectorIn && head[1] != selectorNotIn) || !strings.HasSuffix(term, ")") {
+			return labelRequirement{}, fmt.Errorf("条件[%s]应为 key in (a,b) 或 key notin (a,b)", term)
+		}
+		var values []string
+		for _, value := range strings.Split(term[open+1:len(term)-1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return labelRequirement{}, fmt.Errorf("条件[%s]的取值不能为空", term)
+		}
+		return newRequirement(head[0], head[1], values...)
+	}
+
+	switch {
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		return newRequirement(strings.TrimSpace(term[1:]), selectorNotExists)
+	case strings.Contains(term, "!="):
+		return parseEquality(term, "!=", selectorNotEquals)
+	case strings.Contains(term, "=="):
+		return parseEquality(term, "==", selectorEquals)
+	case strings.Contains(term, "="):
+		return parseEquality(term, "=", selectorEquals)
+	default:
+		return newRequirement(term, selectorExists)
+	}
+}
+
+// parseEquality 解析 key=value、key==value 和 key!=value，
+// version= 这样的空取值多半是漏写了版本号，按格式错误处理，判断元数据是否存在应使用key或!key
+func parseEquality(term, sep, op string) (labelRequirement, error) {
+	key, value, _ := strings.Cut(term, sep)
+	if value = strings.TrimSpace(value); value == "" {
+		return labelRequirement{}, fmt.Errorf("条件[%s]的取值不能为空", term)
+	}
+	return newRequirement(key, op, value)
+}
+
+// newRequirement 校验key后创建条件
+func newRequirement(key, op string, values ...string) (labelRequirement, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || strings.ContainsAny(key, " \t!=()") {
+		return labelRequirement{}, fmt.Errorf("无效的key[%s]", key)
+	}
+	return labelRequirement{key: key, op: op, values: values}, nil
+}
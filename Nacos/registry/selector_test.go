@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, text := range []string{
+		"version=",
+		"version==",
+		"version!=",
+		"version= ,zone=cn-east",
+		"version in ((v1),v2)",
+		"version in (v1,(v2))",
+		"version in (v1,v2",
+		"version in v1,v2)",
+		"version in (v1))",
+		"version=v1,,zone=cn-east",
+		",version=v1",
+		"version=v1,",
+		"version in ()",
+		"version in ( , )",
+		"version between (v1)",
+		"=v1",
+		"!",
+		"ver sion=v1",
+	} {
+		if _, err := ParseSelector(text); err == nil {
+			t.Errorf("%q应该解析失败", text)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	canary := map[string]string{MetadataVersion: "v2", MetadataZone: "cn-east", MetadataProtocol: "http", MetadataTags: "canary, blue"}
+	stable := map[string]string{MetadataVersion: "v1", MetadataZone: "cn-west"}
+	noTags := map[string]string{MetadataVersion: "v1", MetadataTags: " , "}
+	var none map[string]string
+
+	tests := []struct {
+		selector string
+		metadata map[string]string
+		want     bool
+	}{
+		{"", none, true},
+		{"  ", canary, true},
+		{"version=v2", canary, true},
+		{"version=v2", stable, false},
+		{"version=v2", none, false},
+		{"version==v2", canary, true},
+		{" version = v2 ", canary, true},
+		{"version!=v2", canary, false},
+		{"version!=v2", stable, true},
+		{"version!=v2", none, true},
+		{"version in (v1, v2)", stable, true},
+		{"version in (v1,v3)", canary, false},
+		{"version in (v1)", none, false},
+		{"zone notin (cn-east)", canary, false},
+		{"zone notin (cn-east,cn-north)", stable, true},
+		{"zone notin (cn-east)", none, true},
+		{"protocol", canary, true},
+		{"protocol", stable, false},
+		{"!protocol", canary, false},
+		{"!protocol", stable, true},
+		{"! protocol", none, true},
+		// tags按标签集合匹配，不是按字符串匹配
+		{"tags=canary", canary, true},
+		{"tags=blue", canary, true},
+		{"tags=can", canary, false},
+		{"tags==canary", stable, false},
+		{"tags in (green,blue)", canary, true},
+		{"tags notin (green,blue)", canary, false},
+		{"tags!=canary", canary, false},
+		{"tags!=canary", stable, true},
+		{"tags!=canary", noTags, true},
+		{"tags!=canary", none, true},
+		{"tags", noTags, false},
+		{"!tags", noTags, true},
+		// 多个条件同时满足
+		{"version=v2,zone=cn-east", canary, true},
+		{"version=v2,zone=cn-west", canary, false},
+		{"version in (v1,v2),!protocol", stable, true},
+		{"version in (v1,v2), tags!=canary", canary, false},
+	}
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("解析%q失败: %v", tt.selector, err)
+			continue
+		}
+		if got := selector.Matches(ServiceInstance{Metadata: tt.metadata}); got != tt.want {
+			t.Errorf("%q匹配%v返回%v，期望%v", tt.selector, tt.metadata, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorFilter(t *testing.T) {
+	instances := []ServiceInstance{
+		{Ip: "10.0.0.3", Metadata: map[string]string{MetadataVersion: "v2"}},
+		{Ip: "10.0.0.1", Metadata: map[string]string{MetadataVersion: "v1"}},
+		{Ip: "10.0.0.2", Metadata: map[string]string{MetadataVersion: "v2"}},
+	}
+	selector := MustParseSelector(" version=v2 ")
+	if selector.String() != "version=v2" || selector.Empty() {
+		t.Errorf("选择器为%q，Empty为%v", selector.String(), selector.Empty())
+	}
+	var ips []string
+	for _, instance := range selector.Filter(instances) {
+		ips = append(ips, instance.Ip)
+	}
+	if got := strings.Join(ips, ","); got != "10.0.0.3,10.0.0.2" {
+		t.Errorf("筛选结果为%s，期望保持原有顺序", got)
+	}
+	if got := (Selector{}).Filter(instances); len(got) != 3 || !(Selector{}).Empty() {
+		t.Errorf("零值选择器筛选出%d个实例", len(got))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParseSelector解析失败时应该panic")
+		}
+	}()
+	MustParseSelector("version=")
+}
@@ -0,0 +1,249 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 实例变化的增量推送：在Registry.Watch的完整列表之上计算新增、删除以及权重/健康状态等变化，
+// 短时间内的多次变化合并为一次推送；另外定时用List做全量对比，防止订阅回调丢失导致本地列表过期
+
+const (
+	defaultWatchDebounce = 500 * time.Millisecond //收到变化后等待多久再计算差异
+	defaultWatchResync   = time.Minute            //全量对比的间隔
+)
+
+// ChangeKind 实例变化类型，取值与配置差异的变更类型相同
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"   //新增
+	ChangeRemoved  ChangeKind = "removed" //删除
+	ChangeModified ChangeKind = "changed" //修改
+)
+
+// InstanceChange 单个实例的变化
+type InstanceChange struct {
+	Kind   ChangeKind
+	Old    ServiceInstance //新增时为空
+	New    ServiceInstance //删除时为空
+	Fields []string        //修改时变化的字段: weight、healthy、enabled、cluster、metadata
+}
+
+func (c InstanceChange) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return "+ " + c.New.String()
+	case ChangeRemoved:
+		return "- " + c.Old.String()
+	default:
+		return fmt.Sprintf("~ %s [%s]", c.New, strings.Join(c.Fields, ","))
+	}
+}
+
+// InstanceDelta 一次推送的实例变化
+type InstanceDelta struct {
+	Service   string
+	Group     string
+	Changes   []InstanceChange  //按实例ID排序
+	Instances []ServiceInstance //变化后的完整实例列表
+	Resync    bool              //由定时全量对比发现的变化，说明之前有订阅回调丢失
+}
+
+// Empty 是否没有任何变化
+func (d InstanceDelta) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// InstanceWatcher 以增量方式订阅服务的实例变化
+type InstanceWatcher struct {
+	mutex    sync.Mutex
+	registry Registry
+	service  string
+	group    string
+	debounce time.Duration
+	resync   time.Duration
+	selector Selector
+}
+
+// NewInstanceWatcher 创建实例变化订阅
+func NewInstanceWatcher(registry Registry, service, group string) *InstanceWatcher {
+	return &InstanceWatcher{
+		registry: registry,
+		service:  service,
+		group:    defaultGroup(group),
+		debounce: defaultWatchDebounce,
+		resync:   defaultWatchResync,
+	}
+}
+
+// SetDebounce 设置合并变化的时间窗口，0表示每次变化立即推送
+func (w *InstanceWatcher) SetDebounce(debounce time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.debounce = debounce
+}
+
+// SetResyncInterval 设置全量对比的间隔，0表示不做全量对比
+// 对NacosRegistry，List(SelectAllInstances)与订阅回调读取的是SDK的同一份本地缓存，
+// 全量对比只能补上丢失的回调，SDK缓存本身没有从服务端刷新时对比不出差异
+func (w *InstanceWatcher) SetResyncInterval(interval time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.resync = interval
+}
+
+// SetSelector 只关注满足标签选择器的实例，不满足的实例的变化不会推送，
+// 实例的元数据变得不满足时作为删除推送
+func (w *InstanceWatcher) SetSelector(selector Selector) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.selector = selector
+}
+
+// Watch 开始订阅，第一次推送当前的全部实例(作为新增，列表为空时也会推送)，之后只推送有变化的增量；
+// 增量不会丢弃，消费者处理慢时订阅会等待，期间的变化合并到下一次推送；ctx结束后关闭channel
+func (w *InstanceWatcher) Watch(ctx context.Context) (<-chan InstanceDelta, error) {
+	w.mutex.Lock()
+	debounce, resync, selector := w.debounce, w.resync, w.selector
+	w.mutex.Unlock()
+
+	updates, err := w.registry.Watch(ctx, w.service, w.group)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan InstanceDelta, 16)
+	go w.run(ctx, updates, out, debounce, resync, selector)
+	return out, nil
+}
+
+// run 合并变化、定时全量对比并推送增量
+func (w *InstanceWatcher) run(ctx context.Context, updates <-chan []ServiceInstance, out chan<- InstanceDelta, debounce, resync time.Duration, selector Selector) {
+	defer close(out)
+
+	var resyncC <-chan time.Time
+	if resync > 0 {
+		ticker := time.NewTicker(resync)
+		defer ticker.Stop()
+		resyncC = ticker.C
+	}
+	debounceTimer := time.NewTimer(debounce)
+	debounceTimer.Stop()
+	defer debounceTimer.Stop()
+
+	var current []ServiceInstance
+	var pending []ServiceInstance
+	initialized, hasPending := false, false
+
+	emit := func(instances []ServiceInstance, resynced bool) bool {
+		instances = selector.Filter(instances)
+		delta := InstanceDelta{
+			Service:   w.service,
+			Group:     w.group,
+			Changes:   diffInstances(current, instances),
+			Instances: instances,
+			Resync:    resynced,
+		}
+		if initialized && delta.Empty() {
+			return true
+		}
+		initialized = true
+		current = instances
+		select {
+		case out <- delta:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case instances, ok := <-updates:
+			if !ok {
+				return
+			}
+			if !initialized || debounce <= 0 {
+				if !emit(instances, false) {
+					return
+				}
+				continue
+			}
+			// 时间窗口从第一次变化开始计算，持续变化时也能按时推送
+			if !hasPending {
+				debounceTimer.Reset(debounce)
+			}
+			pending, hasPending = instances, true
+		case <-debounceTimer.C:
+			hasPending = false
+			if !emit(pending, false) {
+				return
+			}
+		case <-resyncC:
+			if hasPending || !initialized {
+				continue
+			}
+			instances, err := w.registry.List(w.service, w.group)
+			if err != nil {
+				logf("全量同步服务[%s/%s]的实例失败: %v\n", w.group, w.service, err)
+				continue
+			}
+			if !emit(instances, true) {
+				return
+			}
+		}
+	}
+}
+
+// diffInstances 按实例ID对比两份实例列表，两份列表都已按ID排序
+func diffInstances(before, after []ServiceInstance) []InstanceChange {
+	var changes []InstanceChange
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case j == len(after) || (i < len(before) && before[i].ID < after[j].ID):
+			changes = append(changes, InstanceChange{Kind: ChangeRemoved, Old: before[i]})
+			i++
+		case i == len(before) || after[j].ID < before[i].ID:
+			changes = append(changes, InstanceChange{Kind: ChangeAdded, New: after[j]})
+			j++
+		default:
+			if fields := changedInstanceFields(before[i], after[j]); len(fields) > 0 {
+				changes = append(changes, InstanceChange{Kind: ChangeModified, Old: before[i], New: after[j], Fields: fields})
+			}
+			i++
+			j++
+		}
+	}
+	return changes
+}
+
+// changedInstanceFields 同一个实例发生变化的字段
+func changedInstanceFields(before, after ServiceInstance) []string {
+	var fields []string
+	if before.Weight != after.Weight {
+		fields = append(fields, "weight")
+	}
+	if before.Healthy != after.Healthy {
+		fields = append(fields, "healthy")
+	}
+	if before.Enabled != after.Enabled {
+		fields = append(fields, "enabled")
+	}
+	if before.Cluster != after.Cluster {
+		fields = append(fields, "cluster")
+	}
+	if !maps.Equal(before.Metadata, after.Metadata) {
+		fields = append(fields, "metadata")
+	}
+	return fields
+}
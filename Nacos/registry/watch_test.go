@@ -0,0 +1,245 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nextDelta 等待下一次增量推送
+func nextDelta(t *testing.T, deltas <-chan InstanceDelta) InstanceDelta {
+	t.Helper()
+	select {
+	case delta, ok := <-deltas:
+		if !ok {
+			t.Fatal("InstanceWatcher的channel已关闭")
+		}
+		return delta
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待实例变化推送超时")
+		return InstanceDelta{}
+	}
+}
+
+// noDelta 确认一段时间内没有推送
+func noDelta(t *testing.T, deltas <-chan InstanceDelta) {
+	t.Helper()
+	select {
+	case delta := <-deltas:
+		t.Fatalf("不应推送实例变化: %s", describeChanges(delta))
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+// describeChanges 把增量转换为 类型:实例:字段 的列表，便于比较
+func describeChanges(delta InstanceDelta) string {
+	var out []string
+	for _, change := range delta.Changes {
+		instance := change.New
+		if change.Kind == ChangeRemoved {
+			instance = change.Old
+		}
+		item := string(change.Kind) + ":" + instance.Ip
+		if len(change.Fields) > 0 {
+			item += ":" + strings.Join(change.Fields, ",")
+		}
+		out = append(out, item)
+	}
+	return strings.Join(out, " ")
+}
+
+// startWatcher 以给定的时间窗口和全量对比间隔开始订阅
+func startWatcher(t *testing.T, r Registry, debounce, resync time.Duration, selector Selector) <-chan InstanceDelta {
+	t.Helper()
+	watcher := NewInstanceWatcher(r, "myservice", "")
+	watcher.SetDebounce(debounce)
+	watcher.SetResyncInterval(resync)
+	watcher.SetSelector(selector)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	deltas, err := watcher.Watch(ctx)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	return deltas
+}
+
+func TestInstanceWatcherChanges(t *testing.T) {
+	r := NewMemoryRegistry()
+	r.Register(testInstance("10.0.0.2", 8080))
+	r.Register(testInstance("10.0.0.1", 8080))
+	deltas := startWatcher(t, r, 0, 0, Selector{})
+
+	// 第一次推送当前的全部实例，作为新增
+	delta := nextDelta(t, deltas)
+	if got := describeChanges(delta); got != "added:10.0.0.1 added:10.0.0.2" || delta.Resync || delta.Group != "DEFAULT_GROUP" || len(delta.Instances) != 2 {
+		t.Fatalf("首次推送为%s(%+v)", got, delta)
+	}
+
+	steps := []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"新增", func() { r.Register(testInstance("10.0.0.3", 8080)) }, "added:10.0.0.3"},
+		{"禁用", func() { r.SetEnabled(testInstance("10.0.0.1", 8080), false) }, "changed:10.0.0.1:enabled"},
+		{"不健康", func() { r.SetHealthy(testInstance("10.0.0.2", 8080), false) }, "changed:10.0.0.2:healthy"},
+		{"修改权重和元数据", func() {
+			instance := testInstance("10.0.0.3", 8080)
+			instance.Weight = 2
+			r.Register(instance.WithMetadata(InstanceMetadata{Version: "v2"}))
+		}, "changed:10.0.0.3:weight,metadata"},
+		{"删除", func() { r.Deregister(testInstance("10.0.0.2", 8080)) }, "removed:10.0.0.2"},
+	}
+	for _, step := range steps {
+		step.change()
+		delta := nextDelta(t, deltas)
+		if got := describeChanges(delta); got != step.want {
+			t.Errorf("%s: 推送的变化为%s，期望%s", step.name, got, step.want)
+		}
+	}
+
+	// 修改时Old和New分别是修改前后的实例，删除时New为空
+	r.SetEnabled(testInstance("10.0.0.1", 8080), true)
+	change := nextDelta(t, deltas).Changes[0]
+	if change.Old.Enabled || !change.New.Enabled {
+		t.Errorf("启用实例的变化为%+v", change)
+	}
+	r.Deregister(testInstance("10.0.0.1", 8080))
+	delta = nextDelta(t, deltas)
+	if change := delta.Changes[0]; change.Old.Ip != "10.0.0.1" || change.New.Ip != "" {
+		t.Errorf("删除实例的变化为%+v", change)
+	}
+	if len(delta.Instances) != 1 || delta.Instances[0].Ip != "10.0.0.3" {
+		t.Errorf("删除后的实例列表为%v", delta.Instances)
+	}
+
+	// 重复注册相同的实例没有变化，不推送
+	instance := testInstance("10.0.0.3", 8080)
+	instance.Weight = 2
+	r.Register(instance.WithMetadata(InstanceMetadata{Version: "v2"}))
+	noDelta(t, deltas)
+}
+
+func TestInstanceWatcherEmptyInitialList(t *testing.T) {
+	deltas := startWatcher(t, NewMemoryRegistry(), 0, 0, Selector{})
+	if delta := nextDelta(t, deltas); !delta.Empty() || len(delta.Instances) != 0 {
+		t.Errorf("没有实例时首次推送%+v", delta)
+	}
+}
+
+func TestInstanceWatcherDebounce(t *testing.T) {
+	r := NewMemoryRegistry()
+	r.Register(testInstance("10.0.0.1", 8080))
+	deltas := startWatcher(t, r, 100*time.Millisecond, 0, Selector{})
+	nextDelta(t, deltas)
+
+	// 时间窗口内的多次变化合并为一次推送，先加后删的实例不出现
+	r.Register(testInstance("10.0.0.2", 8080))
+	r.Register(testInstance("10.0.0.3", 8080))
+	r.SetEnabled(testInstance("10.0.0.1", 8080), false)
+	r.Register(testInstance("10.0.0.4", 8080))
+	r.Deregister(testInstance("10.0.0.4", 8080))
+	delta := nextDelta(t, deltas)
+	if got := describeChanges(delta); got != "changed:10.0.0.1:enabled added:10.0.0.2 added:10.0.0.3" {
+		t.Errorf("合并后推送的变化为%s", got)
+	}
+	if len(delta.Instances) != 3 {
+		t.Errorf("合并后的实例列表为%v", delta.Instances)
+	}
+	noDelta(t, deltas)
+
+	// 窗口内变化又恢复原状时不推送
+	r.SetEnabled(testInstance("10.0.0.1", 8080), true)
+	r.SetEnabled(testInstance("10.0.0.1", 8080), false)
+	noDelta(t, deltas)
+}
+
+// lossyRegistry 只推送第一份实例列表，模拟订阅回调丢失
+type lossyRegistry struct {
+	*MemoryRegistry
+}
+
+// Watch 覆盖MemoryRegistry.Watch
+func (r lossyRegistry) Watch(ctx context.Context, service, group string) (<-chan []ServiceInstance, error) {
+	updates, err := r.MemoryRegistry.Watch(ctx, service, group)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan []ServiceInstance, 1)
+	go func() {
+		defer close(out)
+		out <- <-updates
+		for range updates {
+		}
+	}()
+	return out, nil
+}
+
+func TestInstanceWatcherResync(t *testing.T) {
+	r := lossyRegistry{NewMemoryRegistry()}
+	r.Register(testInstance("10.0.0.1", 8080))
+	deltas := startWatcher(t, r, 0, 50*time.Millisecond, Selector{})
+	if delta := nextDelta(t, deltas); delta.Resync {
+		t.Fatal("首次推送不应标记为全量对比")
+	}
+
+	// 订阅回调丢失，由全量对比发现
+	r.Register(testInstance("10.0.0.2", 8080))
+	delta := nextDelta(t, deltas)
+	if got := describeChanges(delta); got != "added:10.0.0.2" || !delta.Resync {
+		t.Errorf("全量对比推送的变化为%s，Resync为%v", got, delta.Resync)
+	}
+
+	// 没有差异时不推送
+	noDelta(t, deltas)
+}
+
+func TestInstanceWatcherSelector(t *testing.T) {
+	r := NewMemoryRegistry()
+	v1 := testInstance("10.0.0.1", 8080).WithMetadata(InstanceMetadata{Version: "v1"})
+	v2 := testInstance("10.0.0.2", 8080).WithMetadata(InstanceMetadata{Version: "v2"})
+	r.Register(v1)
+	r.Register(v2)
+	deltas := startWatcher(t, r, 0, 0, MustParseSelector("version=v2"))
+
+	delta := nextDelta(t, deltas)
+	if got := describeChanges(delta); got != "added:10.0.0.2" || len(delta.Instances) != 1 {
+		t.Fatalf("首次推送为%s，实例列表为%v", got, delta.Instances)
+	}
+
+	// 不满足选择器的实例的变化不推送
+	r.SetEnabled(v1, false)
+	noDelta(t, deltas)
+
+	// 元数据变得不满足时作为删除推送，变得满足时作为新增推送
+	r.Register(v2.WithMetadata(InstanceMetadata{Version: "v1"}))
+	if got := describeChanges(nextDelta(t, deltas)); got != "removed:10.0.0.2" {
+		t.Errorf("v2改为v1后推送的变化为%s", got)
+	}
+	r.Register(v1.WithMetadata(InstanceMetadata{Version: "v2"}))
+	delta = nextDelta(t, deltas)
+	if got := describeChanges(delta); got != "added:10.0.0.1" || len(delta.Instances) != 1 {
+		t.Errorf("v1改为v2后推送的变化为%s，实例列表为%v", got, delta.Instances)
+	}
+}
+
+func TestInstanceWatcherClosesOnCancel(t *testing.T) {
+	watcher := NewInstanceWatcher(NewMemoryRegistry(), "myservice", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	deltas, err := watcher.Watch(ctx)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	nextDelta(t, deltas)
+	cancel()
+	select {
+	case _, ok := <-deltas:
+		if ok {
+			t.Fatal("取消后不应再推送")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("取消后channel没有关闭")
+	}
+}
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/model"
+)
+
+// StandinServer 本地的Nacos替身服务，实现了nacos-sdk-go v1.1.4 用到的v1 open API子集：
+//   配置: 查询/搜索/发布/删除、长轮询监听
+//   服务: 实例注册/注销/更新/查询、心跳、服务列表、健康检查
+// 不需要Docker就可以端到端地运行officialdemo.go和officialServerFindDemo.go；
+// 可以通过 nacosctl standin(go run -tags nacosctl . standin)独立运行，也可以在测试中用 httptest.NewServer(NewStandinServer()) 嵌入
+// 数据只保存在内存中，不支持集群、鉴权和UDP推送(SDK会按cacheMillis定时拉取实例列表)
+
+const (
+	standinBeatInterval    = 5 * time.Second  //要求客户端发送心跳的间隔
+	standinUnhealthyAfter  = 15 * time.Second //临时实例超过该时间没有心跳标记为不健康
+	standinExpireAfter     = 30 * time.Second //临时实例超过该时间没有心跳直接摘除
+	standinLongPollDefault = 30 * time.Second //客户端没有指定长轮询超时时的默认值
+	standinCacheMillis     = 10000            //建议客户端刷新实例列表的间隔(毫秒)
+	standinDefaultCluster  = "DEFAULT"
+	standinPublicNamespace = "public"
+)
+
+// StandinServer 见文件开头的说明
+type StandinServer struct {
+	mutex       sync.Mutex
+	configs     map[string]*standinConfig              //tenant+group+dataId -> 配置
+	changed     chan struct{}                          //任意配置变化时关闭，用于唤醒长轮询
+	services    map[string]map[string]*standinInstance //namespace##group@@service -> instanceId -> 实例
+	now         func() time.Time
+	mux         *http.ServeMux
+	CacheMillis uint64 //返回给客户端的实例列表刷新间隔，0表示使用默认值
+}
+
+// standinConfig 一份配置
+type standinConfig struct {
+	tenant     string
+	dataId     string
+	group      string
+	content    string
+	md5        string
+	configType string
+	appName    string
+	modified   time.Time
+}
+
+// standinInstance 一个服务实例
+type standinInstance struct {
+	model.Instance
+	groupedName string
+	lastBeat    time.Time
+}
+
+// NewStandinServer 创建替身服务
+func NewStandinServer() *StandinServer {
+	s := &StandinServer{
+		configs:  make(map[string]*standinConfig),
+		changed:  make(chan struct{}),
+		services: make(map[string]map[string]*standinInstance),
+		now:      time.Now,
+		mux:      http.NewServeMux(),
+	}
+	base := constant.WEB_CONTEXT
+	s.mux.HandleFunc(base+constant.CONFIG_PATH, s.handleConfigs)
+	s.mux.HandleFunc(base+constant.CONFIG_LISTEN_PATH, s.handleListen)
+	s.mux.HandleFunc(base+constant.SERVICE_PATH, s.handleInstance)
+	s.mux.HandleFunc(base+constant.SERVICE_SUBSCRIBE_PATH, s.handleInstanceList)
+	s.mux.HandleFunc(base+constant.SERVICE_BASE_PATH+"/instance/beat", s.handleBeat)
+	s.mux.HandleFunc(base+constant.SERVICE_INFO_PATH+"/list", s.handleServiceList)
+	s.mux.HandleFunc(base+constant.SERVICE_BASE_PATH+"/operator/metrics", s.handleMetrics)
+	return s
+}
+
+// ServeHTTP 实现http.Handler接口
+func (s *StandinServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// StandinServerConfig 把替身服务的地址(例如httptest.Server.URL)转换为SDK的ServerConfig
+func StandinServerConfig(rawURL string) (constant.ServerConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return constant.ServerConfig{}, err
+	}
+	host, portText, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return constant.ServerConfig{}, fmt.Errorf("地址%q中缺少端口: %v", rawURL, err)
+	}
+	port, err := strconv.ParseUint(portText, 10, 64)
+	if err != nil {
+		return constant.ServerConfig{}, fmt.Errorf("地址%q的端口无效: %v", rawURL, err)
+	}
+	return constant.ServerConfig{
+		IpAddr:      host,
+		Port:        port,
+		ContextPath: constant.WEB_CONTEXT,
+		Scheme:      u.Scheme,
+	}, nil
+}
+
+// handleConfigs 配置的查询、搜索、发布和删除
+func (s *StandinServer) handleConfigs(w http.ResponseWriter, r *http.Request) {
+	params := requestParams(r)
+	tenant := params.Get("tenant")
+	switch r.Method {
+	case http.MethodGet:
+		if search := params.Get("search"); search != "" {
+			s.searchConfigs(w, tenant, search, params)
+			return
+		}
+		dataId, group, ok := requireConfigKey(w, params)
+		if !ok {
+			return
+		}
+		s.mutex.Lock()
+		config, exists := s.configs[standinConfigKey(tenant, group, dataId)]
+		s.mutex.Unlock()
+		if !exists {
+			http.Error(w, "config data not exist", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Config-Type", config.configType)
+		w.Header().Set("Content-MD5", config.md5)
+		fmt.Fprint(w, config.content)
+
+	case http.MethodPost:
+		dataId, group, ok := requireConfigKey(w, params)
+		if !ok {
+			return
+		}
+		content := params.Get("content")
+		if content == "" {
+			http.Error(w, "content is required", http.StatusBadRequest)
+			return
+		}
+		s.mutex.Lock()
+		key := standinConfigKey(tenant, group, dataId)
+		old := s.configs[key]
+		s.configs[key] = &standinConfig{
+			tenant:     tenant,
+			dataId:     dataId,
+			group:      group,
+			content:    content,
+			md5:        contentMD5(content),
+			configType: params.Get("type"),
+			appName:    params.Get("appName"),
+			modified:   s.now(),
+		}
+		if old == nil || old.md5 != s.configs[key].md5 {
+			s.notifyChanged()
+		}
+		s.mutex.Unlock()
+		fmt.Fprint(w, "true")
+
+	case http.MethodDelete:
+		dataId, group, ok := requireConfigKey(w, params)
+		if !ok {
+			return
+		}
+		s.mutex.Lock()
+		key := standinConfigKey(tenant, group, dataId)
+		if _, exists := s.configs[key]; exists {
+			delete(s.configs, key)
+			s.notifyChanged()
+		}
+		s.mutex.Unlock()
+		fmt.Fprint(w, "true")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// searchConfigs 搜索配置，blur模式下dataId和group支持*通配
+func (s *StandinServer) searchConfigs(w http.ResponseWriter, tenant, search string, params url.Values) {
+	if search != "accurate" && search != "blur" {
+		http.Error(w, "search must be accurate or blur", http.StatusBadRequest)
+		return
+	}
+	pageNo := atoiDefault(params.Get("pageNo"), 1)
+	pageSize := atoiDefault(params.Get("pageSize"), 10)
+
+	s.mutex.Lock()
+	var matched []model.ConfigItem
+	for _, config := range s.configs {
+		if config.tenant != tenant {
+			continue
+		}
+		if !searchMatch(search, params.Get("dataId"), config.dataId) || !searchMatch(search, params.Get("group"), config.group) {
+			continue
+		}
+		matched = append(matched, model.ConfigItem{
+			DataId:  config.dataId,
+			Group:   config.group,
+			Content: config.content,
+			Md5:     config.md5,
+			Tenant:  config.tenant,
+			Appname: config.appName,
+		})
+	}
+	s.mutex.Unlock()
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Group != matched[j].Group {
+			return matched[i].Group < matched[j].Group
+		}
+		return matched[i].DataId < matched[j].DataId
+	})
+
+	page := model.ConfigPage{
+		TotalCount:     len(matched),
+		PageNumber:     pageNo,
+		PagesAvailable: (len(matched) + pageSize - 1) / pageSize,
+		PageItems:      []model.ConfigItem{},
+	}
+	if start := (pageNo - 1) * pageSize; start < len(matched) {
+		end := start + pageSize
+		if end > len(matched) {
+			end = len(matched)
+		}
+		page.PageItems = matched[start:end]
+	}
+	writeJSON(w, page)
+}
+
+// handleListen 长轮询监听：请求中列出客户端持有的MD5，有配置变化时立即返回变化的配置，
+// 否则挂起到超时后返回空内容
+func (s *StandinServer) handleListen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	params := requestParams(r)
+	probes := parseListeningConfigs(params.Get("Listening-Configs"), params.Get("tenant"))
+	if len(probes) == 0 {
+		http.Error(w, "invalid probeModify", http.StatusBadRequest)
+		return
+	}
+
+	timeout := standinLongPollDefault
+	if ms, err := strconv.Atoi(r.Header.Get("Long-Pulling-Timeout")); err == nil && ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	// 与Nacos一样提前500毫秒返回，避免客户端读超时
+	if timeout > time.Second {
+		timeout -= 500 * time.Millisecond
+	}
+	noHangup := r.Header.Get("Long-Pulling-Timeout-No-Hangup") == "true"
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.mutex.Lock()
+		changed := s.changedConfigs(probes)
+		wait := s.changed
+		s.mutex.Unlock()
+
+		if len(changed) > 0 || noHangup {
+			fmt.Fprint(w, url.QueryEscape(strings.Join(changed, "")))
+			return
+		}
+		select {
+		case <-wait:
+		case <-timer.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// listenProbe 长轮询请求中的一项
+type listenProbe struct {
+	dataId string
+	group  string
+	md5    string
+	tenant string
+}
+
+// parseListeningConfigs 解析 dataId^2group^2md5[^2tenant]^1 格式的监听列表
+func parseListeningConfigs(raw, tenant string) []listenProbe {
+	var probes []listenProbe
+	for _, line := range strings.Split(raw, constant.SPLIT_CONFIG) {
+		attrs := strings.Split(line, constant.SPLIT_CONFIG_INNER)
+		if len(attrs) < 3 {
+			continue
+		}
+		probe := listenProbe{dataId: attrs[0], group: attrs[1], md5: attrs[2], tenant: tenant}
+		if len(attrs) >= 4 {
+			probe.tenant = attrs[3]
+		}
+		probes = append(probes, probe)
+	}
+	return probes
+}
+
+// changedConfigs 返回MD5与客户端不一致的配置，调用方需持有mutex
+func (s *StandinServer) changedConfigs(probes []listenProbe) []string {
+	var changed []string
+	for _, probe := range probes {
+		md5sum := ""
+		if config, ok := s.configs[standinConfigKey(probe.tenant, probe.group, probe.dataId)]; ok {
+			md5sum = config.md5
+		}
+		if md5sum == probe.md5 {
+			continue
+		}
+		entry := probe.dataId + constant.SPLIT_CONFIG_INNER + probe.group
+		if probe.tenant != "" {
+			entry += constant.SPLIT_CONFIG_INNER + probe.tenant
+		}
+		changed = append(changed, entry+constant.SPLIT_CONFIG)
+	}
+	return changed
+}
+
+// notifyChanged 唤醒所有长轮询，调用方需持有mutex
+func (s *StandinServer) notifyChanged() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// handleInstance 实例的注册、注销和更新
+func (s *StandinServer) handleInstance(w http.ResponseWriter, r *http.Request) {
+	params := requestParams(r)
+	groupedName := groupedServiceName(params.Get("serviceName"), params.Get("groupName"))
+	if strings.HasSuffix(groupedName, constant.SERVICE_INFO_SPLITER) {
+		http.Error(w, "serviceName is required", http.StatusBadRequest)
+		return
+	}
+	ip := params.Get("ip")
+	port, err := strconv.ParseUint(params.Get("port"), 10, 64)
+	if ip == "" || err != nil {
+		http.Error(w, "ip and port are required", http.StatusBadRequest)
+		return
+	}
+	cluster := params.Get("clusterName")
+	if cluster == "" {
+		cluster = standinDefaultCluster
+	}
+	serviceKey := standinServiceKey(params.Get("namespaceId"), groupedName)
+	id := standinInstanceId(ip, port, cluster, groupedName)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch r.Method {
+	case http.MethodPost:
+		metadata, err := parseMetadata(params.Get("metadata"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		instances := s.services[serviceKey]
+		if instances == nil {
+			instances = make(map[string]*standinInstance)
+			s.services[serviceKey] = instances
+		}
+		instances[id] = &standinInstance{
+			Instance: model.Instance{
+				InstanceId:  id,
+				Ip:          ip,
+				Port:        port,
+				Weight:      parseFloatDefault(params.Get("weight"), 1),
+				Enable:      parseBoolDefault(params.Get("enable"), true),
+				Healthy:     parseBoolDefault(params.Get("healthy"), true),
+				Ephemeral:   parseBoolDefault(params.Get("ephemeral"), true),
+				ClusterName: cluster,
+				ServiceName: groupedName,
+				Metadata:    metadata,
+			},
+			groupedName: groupedName,
+			lastBeat:    s.now(),
+		}
+		fmt.Fprint(w, "ok")
+
+	case http.MethodDelete:
+		if instances := s.services[serviceKey]; instances != nil {
+			delete(instances, id)
+		}
+		fmt.Fprint(w, "ok")
+
+	case http.MethodPut:
+		instance, ok := s.services[serviceKey][id]
+		if !ok {
+			http.Error(w, "instance not exist: "+id, http.StatusNotFound)
+			return
+		}
+		if weight := params.Get("weight"); weight != "" {
+			instance.Weight = parseFloatDefault(weight, instance.Weight)
+		}
+		if enable := params.Get("enable"); enable != "" {
+			instance.Enable = parseBoolDefault(enable, instance.Enable)
+		}
+		if raw := params.Get("metadata"); raw != "" {
+			metadata, err := parseMetadata(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			instance.Metadata = metadata
+		}
+		fmt.Fprint(w, "ok")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleInstanceList 查询服务的实例列表，返回SDK解析的model.Service结构
+func (s *StandinServer) handleInstanceList(w http.ResponseWriter, r *http.Request) {
+	params := requestParams(r)
+	groupedName := groupedServiceName(params.Get("serviceName"), params.Get("groupName"))
+	clusters := params.Get("clusters")
+	healthyOnly := parseBoolDefault(params.Get("healthyOnly"), false)
+
+	var wanted map[string]bool
+	if clusters != "" {
+		wanted = make(map[string]bool)
+		for _, c := range strings.Split(clusters, ",") {
+			wanted[strings.TrimSpace(c)] = true
+		}
+	}
+
+	s.mutex.Lock()
+	hosts := []model.Instance{}
+	for _, instance := range s.liveInstances(standinServiceKey(params.Get("namespaceId"), groupedName)) {
+		if wanted != nil && !wanted[instance.ClusterName] {
+			continue
+		}
+		if healthyOnly && !instance.Healthy {
+			continue
+		}
+		hosts = append(hosts, instance.Instance)
+	}
+	s.mutex.Unlock()
+
+	cacheMillis := s.CacheMillis
+	if cacheMillis == 0 {
+		cacheMillis = standinCacheMillis
+	}
+	now := uint64(s.now().UnixNano() / int64(time.Millisecond))
+	writeJSON(w, model.Service{
+		Name:        groupedName,
+		Dom:         groupedName,
+		Clusters:    clusters,
+		CacheMillis: cacheMillis,
+		Hosts:       hosts,
+		LastRefTime: now,
+		Checksum:    contentMD5(fmt.Sprint(hosts)),
+	})
+}
+
+// liveInstances 按实例ID排序返回实例，同时按心跳时间更新临时实例的健康状态，调用方需持有mutex
+func (s *StandinServer) liveInstances(serviceKey string) []*standinInstance {
+	instances := s.services[serviceKey]
+	now := s.now()
+	ids := make([]string, 0, len(instances))
+	for id, instance := range instances {
+		if instance.Ephemeral {
+			silent := now.Sub(instance.lastBeat)
+			if silent > standinExpireAfter {
+				delete(instances, id)
+				continue
+			}
+			if silent > standinUnhealthyAfter {
+				instance.Healthy = false
+			}
+		}
+		instance.Valid = instance.Healthy
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := make([]*standinInstance, len(ids))
+	for i, id := range ids {
+		out[i] = instances[id]
+	}
+	return out
+}
+
+// handleBeat 临时实例的心跳；实例已被摘除时按心跳中的信息重新注册，与Nacos 1.x的行为一致
+func (s *StandinServer) handleBeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	params := requestParams(r)
+	var beat model.BeatInfo
+	if err := json.Unmarshal([]byte(params.Get("beat")), &beat); err != nil {
+		http.Error(w, "invalid beat: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupedName := groupedServiceName(params.Get("serviceName"), "")
+	if beat.ServiceName != "" {
+		groupedName = groupedServiceName(beat.ServiceName, "")
+	}
+	cluster := beat.Cluster
+	if cluster == "" {
+		cluster = standinDefaultCluster
+	}
+	serviceKey := standinServiceKey(params.Get("namespaceId"), groupedName)
+	id := standinInstanceId(beat.Ip, beat.Port, cluster, groupedName)
+
+	s.mutex.Lock()
+	instances := s.services[serviceKey]
+	if instances == nil {
+		instances = make(map[string]*standinInstance)
+		s.services[serviceKey] = instances
+	}
+	instance, ok := instances[id]
+	if !ok {
+		weight := beat.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		instance = &standinInstance{
+			Instance: model.Instance{
+				InstanceId:  id,
+				Ip:          beat.Ip,
+				Port:        beat.Port,
+				Weight:      weight,
+				Enable:      true,
+				Ephemeral:   true,
+				ClusterName: cluster,
+				ServiceName: groupedName,
+				Metadata:    beat.Metadata,
+			},
+			groupedName: groupedName,
+		}
+		instances[id] = instance
+	}
+	instance.Healthy = true
+	instance.lastBeat = s.now()
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"clientBeatInterval": standinBeatInterval.Milliseconds(),
+		"code":               10200,
+		"lightBeatEnabled":   false,
+	})
+}
+
+// handleServiceList 分页列出分组下的服务名
+func (s *StandinServer) handleServiceList(w http.ResponseWriter, r *http.Request) {
+	params := requestParams(r)
+	group := params.Get("groupName")
+	if group == "" {
+		group = constant.DEFAULT_GROUP
+	}
+	prefix := standinServiceKey(params.Get("namespaceId"), group+constant.SERVICE_INFO_SPLITER)
+	pageNo := atoiDefault(params.Get("pageNo"), 1)
+	pageSize := atoiDefault(params.Get("pageSize"), 10)
+
+	s.mutex.Lock()
+	var names []string
+	for key := range s.services {
+		if strings.HasPrefix(key, prefix) && len(s.liveInstances(key)) > 0 {
+			names = append(names, strings.TrimPrefix(key, prefix))
+		}
+	}
+	s.mutex.Unlock()
+	sort.Strings(names)
+
+	doms := []string{}
+	if start := (pageNo - 1) * pageSize; start < len(names) {
+		end := start + pageSize
+		if end > len(names) {
+			end = len(names)
+		}
+		doms = names[start:end]
+	}
+	writeJSON(w, model.ServiceList{Count: int64(len(names)), Doms: doms})
+}
+
+// handleMetrics 服务端健康检查
+func (s *StandinServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "UP"})
+}
+
+// requestParams 合并查询参数和表单参数，SDK的GET/PUT/DELETE把参数放在URL中，POST放在表单中
+func requestParams(r *http.Request) url.Values {
+	if err := r.ParseForm(); err != nil {
+		return r.URL.Query()
+	}
+	return r.Form
+}
+
+// requireConfigKey 读取必填的dataId和group
+func requireConfigKey(w http.ResponseWriter, params url.Values) (string, string, bool) {
+	dataId, group := params.Get("dataId"), params.Get("group")
+	if dataId == "" || group == "" {
+		http.Error(w, "dataId and group are required", http.StatusBadRequest)
+		return "", "", false
+	}
+	return dataId, group, true
+}
+
+// standinConfigKey 配置的唯一标识，public命名空间的tenant为空
+func standinConfigKey(tenant, group, dataId string) string {
+	if tenant == standinPublicNamespace {
+		tenant = ""
+	}
+	return path.Join(tenant, group, dataId)
+}
+
+// standinServiceKey 服务的唯一标识，命名空间为空时使用public
+func standinServiceKey(namespace, groupedName string) string {
+	if namespace == "" {
+		namespace = standinPublicNamespace
+	}
+	return namespace + "##" + groupedName
+}
+
+// standinInstanceId 与Nacos相同格式的实例ID
+func standinInstanceId(ip string, port uint64, cluster, groupedName string) string {
+	return fmt.Sprintf("%s#%d#%s#%s", ip, port, cluster, groupedName)
+}
+
+// groupedServiceName 补全 group@@service 格式的服务名
+func groupedServiceName(serviceName, groupName string) string {
+	if strings.Contains(serviceName, constant.SERVICE_INFO_SPLITER) {
+		return serviceName
+	}
+	if groupName == "" {
+		groupName = constant.DEFAULT_GROUP
+	}
+	return groupName + constant.SERVICE_INFO_SPLITER + serviceName
+}
+
+// parseMetadata 解析JSON格式的实例元数据，SDK在没有元数据时会传"null"
+func parseMetadata(raw string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if raw == "" || raw == "null" {
+		return metadata, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return metadata, nil
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func atoiDefault(s string, def int) int {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func parseFloatDefault(s string, def float64) float64 {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return def
+}
+
+func parseBoolDefault(s string, def bool) bool {
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return def
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// startStandinServer 启动替身服务，测试结束时关闭
+func startStandinServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(NewStandinServer())
+	t.Cleanup(func() { stopStandinServer(ts) })
+	return ts
+}
+
+// stopStandinServer 关闭替身服务，重复调用是安全的
+func stopStandinServer(ts *httptest.Server) {
+	// 长轮询请求会一直挂起，先断开连接再关闭
+	ts.CloseClientConnections()
+	ts.Close()
+}
+
+// newStandinConfigClient 启动替身服务，返回连接到它的SDK配置客户端
+func newStandinConfigClient(t *testing.T) config_client.IConfigClient {
+	t.Helper()
+	return connectStandinConfigClient(t, startStandinServer(t))
+}
+
+// connectStandinConfigClient 创建连接到替身服务的SDK配置客户端，缓存目录在测试临时目录下
+func connectStandinConfigClient(t *testing.T, ts *httptest.Server) config_client.IConfigClient {
+	t.Helper()
+	serverConfig, err := StandinServerConfig(ts.URL)
+	if err != nil {
+		t.Fatalf("解析替身服务地址失败: %v", err)
+	}
+	dir := t.TempDir()
+	client, err := clients.NewConfigClient(vo.NacosClientParam{
+		ClientConfig: &constant.ClientConfig{
+			TimeoutMs:           5000,
+			NotLoadCacheAtStart: true,
+			LogDir:              dir + "/log",
+			CacheDir:            dir + "/cache",
+			LogLevel:            "error",
+		},
+		ServerConfigs: []constant.ServerConfig{serverConfig},
+	})
+	if err != nil {
+		t.Fatalf("创建配置客户端失败: %v", err)
+	}
+	return client
+}
+
+// waitContent 等待监听回调收到指定内容，中间的其他内容被忽略
+func waitContent(t *testing.T, changes <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case content := <-changes:
+			if content == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("等待监听回调收到%q超时", want)
+		}
+	}
+}
+
+func TestStandinServerConfigLifecycle(t *testing.T) {
+	client := newStandinConfigClient(t)
+	param := vo.ConfigParam{DataId: "app.yaml", Group: "test"}
+
+	content, err := client.GetConfig(param)
+	if err != nil || content != "" {
+		t.Fatalf("配置不存在时GetConfig返回(%q, %v)，期望空内容", content, err)
+	}
+
+	publish := param
+	publish.Content = "appName: v1\n"
+	publish.Type = vo.YAML
+	if ok, err := client.PublishConfig(publish); err != nil || !ok {
+		t.Fatalf("发布配置失败: %v %v", ok, err)
+	}
+	if content, err := client.GetConfig(param); err != nil || content != publish.Content {
+		t.Fatalf("GetConfig返回(%q, %v)，期望%q", content, err, publish.Content)
+	}
+
+	changes := make(chan string, 16)
+	listen := param
+	listen.OnChange = func(namespace, group, dataId, data string) {
+		changes <- data
+	}
+	if err := client.ListenConfig(listen); err != nil {
+		t.Fatalf("监听配置失败: %v", err)
+	}
+	defer client.CancelListenConfig(param)
+
+	publish.Content = "appName: v2\n"
+	if ok, err := client.PublishConfig(publish); err != nil || !ok {
+		t.Fatalf("更新配置失败: %v %v", ok, err)
+	}
+	waitContent(t, changes, "appName: v2\n")
+
+	if ok, err := client.DeleteConfig(param); err != nil || !ok {
+		t.Fatalf("删除配置失败: %v %v", ok, err)
+	}
+	waitContent(t, changes, "")
+	if content, err := client.GetConfig(param); err != nil || content != "" {
+		t.Errorf("删除后GetConfig返回(%q, %v)，期望空内容", content, err)
+	}
+}
+
+func TestStandinServerSearchConfig(t *testing.T) {
+	client := newStandinConfigClient(t)
+	for _, dataId := range []string{"a.yaml", "b.yaml", "c.json"} {
+		if _, err := client.PublishConfig(vo.ConfigParam{DataId: dataId, Group: "test", Content: "x: 1\n"}); err != nil {
+			t.Fatalf("发布%s失败: %v", dataId, err)
+		}
+	}
+
+	page, err := client.SearchConfig(vo.SearchConfigParam{Search: "blur", DataId: "*.yaml", PageNo: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("搜索配置失败: %v", err)
+	}
+	if page.TotalCount != 2 || len(page.PageItems) != 2 {
+		t.Fatalf("搜索*.yaml得到%d份配置: %+v", page.TotalCount, page.PageItems)
+	}
+	for _, item := range page.PageItems {
+		if item.Content != "x: 1\n" {
+			t.Errorf("%s的内容为%q", item.DataId, item.Content)
+		}
+	}
+}
+
+func TestConfigManagerDetectsSDKCacheFallback(t *testing.T) {
+	ts := startStandinServer(t)
+	client := connectStandinConfigClient(t, ts)
+	param := vo.ConfigParam{DataId: testDataId, Group: testGroup, Content: appConfig("v1", 8080), Type: vo.YAML}
+	if ok, err := client.PublishConfig(param); err != nil || !ok {
+		t.Fatalf("发布配置失败: %v %v", ok, err)
+	}
+
+	// 第一次启动时Nacos可达，写出本地快照，SDK也缓存了配置
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+	first := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	first.SetSnapshotFile(snapshot)
+	if err := first.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	if status := first.Status(); status.Source != SourceNacos || status.Stale {
+		t.Errorf("Nacos可达时的状态为%+v", status)
+	}
+	first.Stop(context.Background())
+
+	// 替身服务停止后，SDK的GetConfig读取缓存文件并且不返回错误
+	stopStandinServer(ts)
+	if content, err := client.GetConfig(vo.ConfigParam{DataId: testDataId, Group: testGroup}); err != nil || content != param.Content {
+		t.Fatalf("服务停止后GetConfig返回(%q, %v)，期望SDK缓存中的内容", content, err)
+	}
+
+	// 配置管理器不能把SDK缓存当作实时配置，应该使用本地快照并标记为过期
+	second := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	second.SetSnapshotFile(snapshot)
+	if err := second.Start(context.Background()); err != nil {
+		t.Fatalf("使用本地快照启动失败: %v", err)
+	}
+	defer second.Stop(context.Background())
+	if status := second.Status(); status.Source != SourceLocalSnapshot || !status.Stale {
+		t.Errorf("Nacos不可达时的状态为%+v，期望来自本地快照且已过期", status)
+	}
+
+	noSnapshot := NewConfigManager[ConfigData](client, testDataId, testGroup, "yaml")
+	if err := noSnapshot.Start(context.Background()); err == nil {
+		noSnapshot.Stop(context.Background())
+		t.Fatal("Nacos不可达且没有本地快照时应该启动失败")
+	}
+}